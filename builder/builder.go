@@ -15,6 +15,11 @@ func NewBuilder(object client.Object) *Builder {
 	return &Builder{Object: object}
 }
 
+// GetName returns the name of the resource created by the builder
+func (b *Builder) GetName() string {
+	return b.Name
+}
+
 func (b *Builder) Labels() MapBuilder {
 	if b.Object.GetLabels() == nil {
 		b.Object.SetLabels(make(map[string]string))
@@ -0,0 +1,233 @@
+package formation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidboxer/formation/builder/overlay"
+	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/types"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The recommended labels, see https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+const (
+	NameLabel      = "app.kubernetes.io/name"
+	InstanceLabel  = "app.kubernetes.io/instance"
+	VersionLabel   = "app.kubernetes.io/version"
+	PartOfLabel    = "app.kubernetes.io/part-of"
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+)
+
+// maxNameLength is the longest name of the types with a limit lower than a DNS subdomain.
+// The StatefulSet and CronJob names are used in a label value with a suffix, the Job name in the job-name label
+var maxNameLength = map[string]int{
+	"service":     validation.DNS1035LabelMaxLength,
+	"statefulset": 52,
+	"cronjob":     52,
+	"job":         validation.LabelValueMaxLength,
+}
+
+// ResourceBuilder is a builder of a resource of the formation, e.g. a ConfigMapBuilder
+type ResourceBuilder interface {
+	ToResource() types.Resource
+}
+
+// ResourcesBuilder is a builder creating several resources, e.g. a DeploymentBuilder and its companions
+type ResourcesBuilder interface {
+	ToResources() []types.Resource
+}
+
+// Formation is an instance of a set of builders, several instances can be deployed in the same namespace.
+// Every resource is named <instance>-<name>, the objects and the pod templates are labeled with the recommended labels
+// and the selectors of the workloads, services, PodDisruptionBudgets and NetworkPolicies also select the instance.
+//
+// The selectors of the Deployments, StatefulSets and DaemonSets are immutable: a workload already created without the
+// instance in its selector is rejected by the API server. Use KeepWorkloadSelectors to adopt such workloads
+type Formation struct {
+	instance  string
+	labels    map[string]string
+	resources []types.Resource
+	// applied is set once the resources are renamed, they can not be renamed twice
+	applied bool
+	// keepWorkloadSelectors the instance is not added to the selectors of the workloads
+	keepWorkloadSelectors bool
+}
+
+func NewFormation(instance string) *Formation {
+	return &Formation{
+		instance: instance,
+		labels:   map[string]string{InstanceLabel: instance},
+	}
+}
+
+// SetName Set the app.kubernetes.io/name label, the name of the application
+func (f *Formation) SetName(name string) *Formation {
+	f.labels[NameLabel] = name
+	return f
+}
+
+// SetVersion Set the app.kubernetes.io/version label
+func (f *Formation) SetVersion(version string) *Formation {
+	f.labels[VersionLabel] = version
+	return f
+}
+
+// SetPartOf Set the app.kubernetes.io/part-of label, the higher level application
+func (f *Formation) SetPartOf(partOf string) *Formation {
+	f.labels[PartOfLabel] = partOf
+	return f
+}
+
+// SetManagedBy Set the app.kubernetes.io/managed-by label, usually the name of the operator
+func (f *Formation) SetManagedBy(managedBy string) *Formation {
+	f.labels[ManagedByLabel] = managedBy
+	return f
+}
+
+// KeepWorkloadSelectors Do not add the instance to the selectors of the Deployments, StatefulSets and DaemonSets,
+// e.g. to adopt workloads created before the formation. Their pod templates are still labeled with the instance
+func (f *Formation) KeepWorkloadSelectors() *Formation {
+	f.keepWorkloadSelectors = true
+	return f
+}
+
+// Add the resources of the builders, ToResources is used if the builder implement ResourcesBuilder
+func (f *Formation) Add(builders ...ResourceBuilder) *Formation {
+	for _, b := range builders {
+		if many, ok := b.(ResourcesBuilder); ok {
+			f.resources = append(f.resources, many.ToResources()...)
+		} else {
+			f.resources = append(f.resources, b.ToResource())
+		}
+	}
+	return f
+}
+
+// AddResources Add resources already created
+func (f *Formation) AddResources(resources ...types.Resource) *Formation {
+	f.resources = append(f.resources, resources...)
+	return f
+}
+
+// Labels returns the recommended labels of the instance
+func (f *Formation) Labels() map[string]string {
+	labels := make(map[string]string, len(f.labels))
+	for k, v := range f.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// SelectorLabels returns the labels selecting the pods of the instance
+func (f *Formation) SelectorLabels() map[string]string {
+	return map[string]string{InstanceLabel: f.instance}
+}
+
+// Build prefix, label and select the objects of the resources in place, and returns the resources.
+// The references between the resources (volumes, envFrom, serviceAccountName, imagePullSecrets, role bindings...) are renamed.
+// It can only be called once, an error is returned on the next calls or if the instance or a name is too long or invalid
+func (f *Formation) Build() ([]types.Resource, error) {
+	if f.applied {
+		return nil, fmt.Errorf("formation %s: the resources are already prefixed and labeled", f.instance)
+	}
+	if errs := validation.IsDNS1123Label(f.instance); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid instance %q: %s", f.instance, strings.Join(errs, ", "))
+	}
+	for key, value := range f.labels {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label %s=%q: %s", key, value, strings.Join(errs, ", "))
+		}
+	}
+	f.applied = true
+	instanceOverlay := &overlay.Overlay{NamePrefix: f.instance + "-", CommonLabels: f.Labels()}
+	if err := instanceOverlay.Apply(f.resources); err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, res := range f.resources {
+		obj, err := common.Object(res)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", res.Type(), res.Name(), err)
+		}
+		f.addSelector(obj)
+		if err := checkNameLength(res, obj); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid formation %s: %s", f.instance, strings.Join(errs, "; "))
+	}
+	return f.resources, nil
+}
+
+// addSelector add the instance to the selectors of the object, the empty service selectors are kept empty.
+// The selectors of the workloads are kept with KeepWorkloadSelectors
+func (f *Formation) addSelector(obj client.Object) {
+	selectLabelSelector := func(selector *metav1.LabelSelector) {
+		if selector.MatchLabels == nil {
+			selector.MatchLabels = map[string]string{}
+		}
+		selector.MatchLabels[InstanceLabel] = f.instance
+	}
+	switch obj.(type) {
+	case *appsv1.Deployment, *appsv1.StatefulSet, *appsv1.DaemonSet:
+		if f.keepWorkloadSelectors {
+			return
+		}
+	}
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		if o.Spec.Selector == nil {
+			o.Spec.Selector = &metav1.LabelSelector{}
+		}
+		selectLabelSelector(o.Spec.Selector)
+	case *appsv1.StatefulSet:
+		if o.Spec.Selector == nil {
+			o.Spec.Selector = &metav1.LabelSelector{}
+		}
+		selectLabelSelector(o.Spec.Selector)
+	case *appsv1.DaemonSet:
+		if o.Spec.Selector == nil {
+			o.Spec.Selector = &metav1.LabelSelector{}
+		}
+		selectLabelSelector(o.Spec.Selector)
+	case *v1.Service:
+		if len(o.Spec.Selector) > 0 {
+			o.Spec.Selector[InstanceLabel] = f.instance
+		}
+	case *policyv1.PodDisruptionBudget:
+		if o.Spec.Selector != nil {
+			selectLabelSelector(o.Spec.Selector)
+		}
+	case *networkingv1.NetworkPolicy:
+		// The peers are not changed, they may select pods outside the formation
+		selectLabelSelector(&o.Spec.PodSelector)
+	}
+}
+
+func checkNameLength(res types.Resource, obj client.Object) error {
+	name := obj.GetName()
+	max := validation.DNS1123SubdomainMaxLength
+	if typeMax, ok := maxNameLength[res.Type()]; ok {
+		max = typeMax
+	}
+	if versioned, ok := res.(types.Versioned); ok {
+		versionedName, err := versioned.VersionedName()
+		if err != nil {
+			return err
+		}
+		name = versionedName
+	}
+	if len(name) > max {
+		return fmt.Errorf("%s %s is %d characters long, the maximum is %d", res.Type(), name, len(name), max)
+	}
+	return nil
+}
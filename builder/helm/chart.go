@@ -0,0 +1,45 @@
+package helm
+
+import (
+	"io"
+	"io/fs"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// LoadChartFS load the chart of the directory, it works with an embed.FS.
+// The subcharts of the charts/ directory are loaded, the .helmignore file is not applied
+func LoadChartFS(fsys fs.FS, dir string) (*chart.Chart, error) {
+	var files []*loader.BufferedFile
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, dir), "/")
+		if dir == "." {
+			rel = name
+		}
+		files = append(files, &loader.BufferedFile{Name: rel, Data: content})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loader.LoadFiles(files)
+}
+
+// LoadChartDir load the chart of the directory, as helm does
+func LoadChartDir(dir string) (*chart.Chart, error) {
+	return loader.LoadDir(dir)
+}
+
+// LoadChartArchive load a chart packaged as a .tgz, as created by helm package
+func LoadChartArchive(reader io.Reader) (*chart.Chart, error) {
+	return loader.LoadArchive(reader)
+}
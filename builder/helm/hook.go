@@ -0,0 +1,282 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidboxer/formation/types"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// HookRevisionAnnotation is the revision of the release the hook object was created for
+	HookRevisionAnnotation = "formation/helm-hook-revision"
+	// HookStatusAnnotation record on the owner the hooks deleted by their delete policy, so they do not run again for the
+	// same revision. It contain the comma separated <type>/<name>=<revision>, suffixed with :failed if the hook failed
+	HookStatusAnnotation = "formation/helm-hooks"
+)
+
+// Hook is a helm hook, its object is created once per revision and is never updated.
+// As with helm, the object of a previous revision is deleted before the new one is created with the
+// before-hook-creation policy (the default), it is an error without it. With the hook-succeeded and hook-failed
+// policies the object is deleted once it completes. A Job or a Pod completes when it succeeds or fails, the other kinds
+// once created. The hook objects are retained when they are removed from the formation, e.g. the pre-install hooks
+// once the release is an upgrade
+type Hook struct {
+	types.Resource
+	types.ConvergedGroup
+	Revision       int
+	DeletePolicies []release.HookDeletePolicy
+	// owner is set by Reconcile, the hooks deleted by their policy are recorded in its annotations
+	owner client.Object
+}
+
+// NewHook wraps the resource of a hook object, the before-hook-creation policy is used if no policy is given
+func NewHook(resource types.Resource, revision int, policies ...release.HookDeletePolicy) *Hook {
+	if len(policies) == 0 {
+		policies = []release.HookDeletePolicy{release.HookBeforeHookCreation}
+	}
+	return &Hook{Resource: resource, Revision: revision, DeletePolicies: policies}
+}
+
+// Object returns the object shared by the resource of the hook, nil if the resource does not share it
+func (h *Hook) Object() client.Object {
+	if shared, ok := h.Resource.(types.SharedObject); ok {
+		return shared.Object()
+	}
+	return nil
+}
+
+// Create returns the object of the hook, retained and annotated with its revision
+func (h *Hook) Create() (client.Object, error) {
+	obj, err := h.Resource.Create()
+	if err != nil {
+		return nil, err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[types.RetainKey] = "true"
+	annotations[HookRevisionAnnotation] = strconv.Itoa(h.Revision)
+	obj.SetAnnotations(annotations)
+	return obj, nil
+}
+
+func (h *Hook) Reconcile(ctx context.Context, cli client.Client, owner metav1.Object) (bool, error) {
+	ownerObj, ok := owner.(client.Object)
+	if !ok {
+		return false, fmt.Errorf("hook %s/%s: the owner is not an object", h.Type(), h.Name())
+	}
+	// The owner recorded by a previous call is more recent than the one of the reconcile loop
+	if h.owner == nil || h.owner.GetUID() != ownerObj.GetUID() {
+		h.owner = ownerObj
+	}
+	obj, err := h.Create()
+	if err != nil {
+		return false, err
+	}
+	current := h.Runtime()
+	if err := cli.Get(ctx, h.key(owner.GetNamespace()), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		// Completed and deleted by its policy
+		if _, ok := h.status(); ok {
+			return false, nil
+		}
+		desired := obj.DeepCopyObject().(client.Object)
+		if !h.clusterScoped() {
+			desired.SetNamespace(owner.GetNamespace())
+			if err := controllerutil.SetOwnerReference(owner, desired, cli.Scheme()); err != nil {
+				return false, err
+			}
+		}
+		return true, cli.Create(ctx, desired)
+	}
+	if current.GetDeletionTimestamp() != nil || h.currentRevision(current) {
+		return false, nil
+	}
+	if !h.hasPolicy(release.HookBeforeHookCreation) {
+		return false, fmt.Errorf("hook %s/%s of a previous revision exists and has no %s policy", h.Type(), h.Name(), release.HookBeforeHookCreation)
+	}
+	return true, h.delete(ctx, cli, current)
+}
+
+func (h *Hook) Converged(ctx context.Context, cli client.Client, namespace string) (bool, error) {
+	current := h.Runtime()
+	if err := cli.Get(ctx, h.key(namespace), current); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		failed, ok := h.status()
+		if ok && failed {
+			return false, fmt.Errorf("hook %s/%s failed", h.Type(), h.Name())
+		}
+		return ok, nil
+	}
+	if current.GetDeletionTimestamp() != nil || !h.currentRevision(current) {
+		return false, nil
+	}
+	phase, err := h.phase(current)
+	if err != nil {
+		return false, err
+	}
+	switch phase {
+	case release.HookPhaseFailed:
+		if h.hasPolicy(release.HookFailed) {
+			if err := h.record(ctx, cli, true); err != nil {
+				return false, err
+			}
+			if err := h.delete(ctx, cli, current); err != nil {
+				return false, err
+			}
+		}
+		return false, fmt.Errorf("hook %s/%s failed", h.Type(), h.Name())
+	case release.HookPhaseSucceeded:
+		if h.hasPolicy(release.HookSucceeded) {
+			if err := h.record(ctx, cli, false); err != nil {
+				return false, err
+			}
+			if err := h.delete(ctx, cli, current); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (h *Hook) key(namespace string) client.ObjectKey {
+	if h.clusterScoped() {
+		namespace = ""
+	}
+	return client.ObjectKey{Name: h.Name(), Namespace: namespace}
+}
+
+func (h *Hook) clusterScoped() bool {
+	scoped, ok := h.Resource.(types.ClusterScoped)
+	return ok && scoped.ClusterScoped()
+}
+
+func (h *Hook) hasPolicy(policy release.HookDeletePolicy) bool {
+	for _, p := range h.DeletePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hook) currentRevision(obj client.Object) bool {
+	return obj.GetAnnotations()[HookRevisionAnnotation] == strconv.Itoa(h.Revision)
+}
+
+// phase returns the phase of a Job or a Pod, the other kinds succeed once created
+func (h *Hook) phase(obj client.Object) (release.HookPhase, error) {
+	if h.Type() != "job" && h.Type() != "pod" {
+		return release.HookPhaseSucceeded, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return release.HookPhaseUnknown, err
+	}
+	if h.Type() == "pod" {
+		phase, _, _ := unstructured.NestedString(content, "status", "phase")
+		switch phase {
+		case "Succeeded":
+			return release.HookPhaseSucceeded, nil
+		case "Failed":
+			return release.HookPhaseFailed, nil
+		}
+		return release.HookPhaseRunning, nil
+	}
+	conditions, _, _ := unstructured.NestedSlice(content, "status", "conditions")
+	for _, c := range conditions {
+		condition, _ := c.(map[string]any)
+		if condition["status"] != "True" {
+			continue
+		}
+		switch condition["type"] {
+		case "Complete":
+			return release.HookPhaseSucceeded, nil
+		case "Failed":
+			return release.HookPhaseFailed, nil
+		}
+	}
+	return release.HookPhaseRunning, nil
+}
+
+func (h *Hook) delete(ctx context.Context, cli client.Client, obj client.Object) error {
+	err := cli.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// status returns if the hook of the revision was recorded as deleted by its policy, and if it failed
+func (h *Hook) status() (failed bool, ok bool) {
+	if h.owner == nil {
+		return false, false
+	}
+	value, ok := hookStatuses(h.owner.GetAnnotations()[HookStatusAnnotation])[h.Type()+"/"+h.Name()]
+	if !ok {
+		return false, false
+	}
+	revision, outcome, _ := strings.Cut(value, ":")
+	if revision != strconv.Itoa(h.Revision) {
+		return false, false
+	}
+	return outcome == "failed", true
+}
+
+// record the outcome of the hook in the owner annotations before its deletion
+func (h *Hook) record(ctx context.Context, cli client.Client, failed bool) error {
+	if h.owner == nil {
+		return fmt.Errorf("hook %s/%s: converged before being reconciled", h.Type(), h.Name())
+	}
+	updated := h.owner.DeepCopyObject().(client.Object)
+	base := updated.DeepCopyObject().(client.Object)
+	annotations := updated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	statuses := hookStatuses(annotations[HookStatusAnnotation])
+	value := strconv.Itoa(h.Revision)
+	if failed {
+		value += ":failed"
+	}
+	statuses[h.Type()+"/"+h.Name()] = value
+	entries := make([]string, 0, len(statuses))
+	for key, value := range statuses {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	annotations[HookStatusAnnotation] = strings.Join(entries, ",")
+	updated.SetAnnotations(annotations)
+	if err := cli.Patch(ctx, updated, client.MergeFrom(base)); err != nil {
+		return err
+	}
+	h.owner = updated
+	return nil
+}
+
+func hookStatuses(value string) map[string]string {
+	statuses := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		if key, status, found := strings.Cut(strings.TrimSpace(entry), "="); found {
+			statuses[key] = status
+		}
+	}
+	return statuses
+}
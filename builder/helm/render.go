@@ -0,0 +1,214 @@
+package helm
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/davidboxer/formation/builder/manifest"
+	"github.com/davidboxer/formation/types"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Release describe the release the chart is rendered for, it is available as .Release in the templates
+type Release struct {
+	Name      string
+	Namespace string
+	// Revision default to 1. The hooks run once per revision, increment it (e.g. with the generation of the CR) to run them again
+	Revision int
+	// IsUpgrade render the chart for an upgrade instead of an install
+	IsUpgrade bool
+}
+
+// Options of the rendering
+type Options struct {
+	Release Release
+	// Values override the default values of the chart, as helm install --values does
+	Values map[string]any
+	// KubeVersion is available as .Capabilities.KubeVersion, default to the version of chartutil.DefaultCapabilities
+	KubeVersion string
+	// APIVersions are added to .Capabilities.APIVersions, e.g. monitoring.coreos.com/v1 or monitoring.coreos.com/v1/ServiceMonitor.
+	// As with helm, an object of an API version not available is an error
+	APIVersions []string
+	// Scheme used to decode the objects into their Go types, see manifest.Options
+	Scheme *runtime.Scheme
+	// GroupOffset is added to the converged group id of the resources, see manifest.Options
+	GroupOffset int
+}
+
+// rendered is the output of the chart, split as helm does
+type rendered struct {
+	crds      []client.Object
+	hooks     []*release.Hook
+	manifests []releaseutil.Manifest
+}
+
+// render renders the chart with the helm engine, the chart is modified as helm does (the disabled dependencies are removed)
+func render(chrt *chart.Chart, opts Options) (*rendered, error) {
+	rel := opts.Release
+	if rel.Revision == 0 {
+		rel.Revision = 1
+	}
+	caps := chartutil.DefaultCapabilities.Copy()
+	if opts.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(opts.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %s: %w", opts.KubeVersion, err)
+		}
+		caps.KubeVersion = *kubeVersion
+	}
+	caps.APIVersions = append(append(chartutil.VersionSet{}, caps.APIVersions...), opts.APIVersions...)
+
+	values := opts.Values
+	if values == nil {
+		values = map[string]any{}
+	}
+	if err := chartutil.ProcessDependenciesWithMerge(chrt, values); err != nil {
+		return nil, err
+	}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, chartutil.ReleaseOptions{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Revision,
+		IsInstall: !rel.IsUpgrade,
+		IsUpgrade: rel.IsUpgrade,
+	}, caps)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, err
+	}
+	for name := range files {
+		if path.Base(name) == "NOTES.txt" {
+			delete(files, name)
+		}
+	}
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &rendered{hooks: hooks, manifests: manifests}
+	for _, crd := range chrt.CRDObjects() {
+		objects, err := manifest.Decode(strings.NewReader(string(crd.File.Data)), manifest.Options{Scheme: opts.Scheme})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", crd.Filename, err)
+		}
+		out.crds = append(out.crds, objects...)
+	}
+	return out, nil
+}
+
+// Render renders the chart with the helm engine and returns the objects: the CRDs of the crds/ directories,
+// the objects of the chart and the hooks. The NOTES.txt and the partials produce no object.
+// The lookup function returns no object, the chart is rendered without cluster access
+func Render(chrt *chart.Chart, opts Options) ([]client.Object, error) {
+	out, err := render(chrt, opts)
+	if err != nil {
+		return nil, err
+	}
+	objects := out.crds
+	for _, m := range out.manifests {
+		decoded, err := manifest.Decode(strings.NewReader(m.Content), manifest.Options{Scheme: opts.Scheme})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Name, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	for _, hook := range out.hooks {
+		decoded, err := manifest.Decode(strings.NewReader(hook.Manifest), manifest.Options{Scheme: opts.Scheme})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hook.Path, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// Resources renders the chart and returns the resources, ordered as helm would install them: the CRDs,
+// the pre-install hooks by weight, the objects of the chart by kind, then the post-install hooks by weight.
+// The pre-upgrade and post-upgrade hooks are used instead when the release is an upgrade. The other hooks are skipped.
+// Each hook weight is a converged group, the next phase starts once all the resources of the group are ready.
+// The hooks are wrapped in Hook, they run once per revision and honor their delete policies
+func Resources(chrt *chart.Chart, opts Options) ([]types.Resource, error) {
+	out, err := render(chrt, opts)
+	if err != nil {
+		return nil, err
+	}
+	revision := opts.Release.Revision
+	if revision == 0 {
+		revision = 1
+	}
+	pre, post := release.HookPreInstall, release.HookPostInstall
+	if opts.Release.IsUpgrade {
+		pre, post = release.HookPreUpgrade, release.HookPostUpgrade
+	}
+	var preHooks, postHooks []*release.Hook
+	for _, hook := range out.hooks {
+		for _, event := range hook.Events {
+			switch event {
+			case pre:
+				preHooks = append(preHooks, hook)
+			case post:
+				postHooks = append(postHooks, hook)
+			}
+		}
+	}
+
+	group := opts.GroupOffset
+	var resources []types.Resource
+	appendResources := func(objects []client.Object) {
+		for _, res := range manifest.FromObjects(objects, manifest.Options{Scheme: opts.Scheme, GroupOffset: group}) {
+			if g, ok := res.(types.ConvergedGroupInterface); ok && g.GetConvergedGroupID() > group {
+				group = g.GetConvergedGroupID()
+			}
+			resources = append(resources, res)
+		}
+	}
+	hookResources := func(hooks []*release.Hook) error {
+		// The hooks are sorted by kind, helm run them by weight
+		sort.SliceStable(hooks, func(a, b int) bool { return hooks[a].Weight < hooks[b].Weight })
+		for idx, hook := range hooks {
+			if idx == 0 || hooks[idx-1].Weight != hook.Weight {
+				group++
+			}
+			objects, err := manifest.Decode(strings.NewReader(hook.Manifest), manifest.Options{Scheme: opts.Scheme})
+			if err != nil {
+				return fmt.Errorf("%s: %w", hook.Path, err)
+			}
+			for _, res := range manifest.FromObjects(objects, manifest.Options{Scheme: opts.Scheme}) {
+				h := NewHook(res, revision, hook.DeletePolicies...)
+				h.SetConvergedGroupID(group)
+				resources = append(resources, h)
+			}
+		}
+		return nil
+	}
+
+	appendResources(out.crds)
+	if err := hookResources(preHooks); err != nil {
+		return nil, err
+	}
+	var objects []client.Object
+	for _, m := range out.manifests {
+		decoded, err := manifest.Decode(strings.NewReader(m.Content), manifest.Options{Scheme: opts.Scheme})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.Name, err)
+		}
+		objects = append(objects, decoded...)
+	}
+	appendResources(objects)
+	if err := hookResources(postHooks); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
@@ -0,0 +1,283 @@
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/davidboxer/formation/resources/apps"
+	"github.com/davidboxer/formation/resources/autoscaling"
+	"github.com/davidboxer/formation/resources/batch"
+	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/resources/networking"
+	"github.com/davidboxer/formation/resources/policy"
+	"github.com/davidboxer/formation/resources/rbac"
+	"github.com/davidboxer/formation/types"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// InstallOrder is the order in which the kinds are reconciled, the kinds not listed are reconciled last
+var InstallOrder = []string{
+	"Namespace", "NetworkPolicy", "ResourceQuota", "LimitRange", "PodSecurityPolicy", "PodDisruptionBudget",
+	"ServiceAccount", "Secret", "SecretList", "ConfigMap", "StorageClass", "PersistentVolume", "PersistentVolumeClaim",
+	"CustomResourceDefinition", "ClusterRole", "ClusterRoleList", "ClusterRoleBinding", "ClusterRoleBindingList",
+	"Role", "RoleList", "RoleBinding", "RoleBindingList", "Service", "DaemonSet", "Pod", "ReplicationController",
+	"ReplicaSet", "Deployment", "HorizontalPodAutoscaler", "StatefulSet", "Job", "CronJob", "IngressClass", "Ingress",
+	"APIService",
+}
+
+// ClusterScopedKinds are the kinds created without namespace nor owner reference
+var ClusterScopedKinds = []string{
+	"Namespace", "PersistentVolume", "StorageClass", "CustomResourceDefinition", "ClusterRole", "ClusterRoleBinding",
+	"PriorityClass", "IngressClass", "APIService", "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration",
+	"PodSecurityPolicy",
+}
+
+// Options of the loader
+type Options struct {
+	// Scheme used to decode the objects into their Go types, the kinds not registered are decoded as unstructured
+	Scheme *runtime.Scheme
+	// Values if set, the manifests are rendered as Go templates over the values before being decoded
+	Values any
+	// GroupOffset is added to the converged group id of the resources, to avoid clashing with the groups of the builders.
+	// The resources of the same kind share a group, the groups follow InstallOrder
+	GroupOffset int
+	// ClusterScopedKinds are added to the ClusterScopedKinds, e.g. the kinds of custom cluster scoped resources
+	ClusterScopedKinds []string
+}
+
+// Load returns the resources of the multi-document YAML
+func Load(reader io.Reader, opts Options) ([]types.Resource, error) {
+	objects, err := Decode(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	return FromObjects(objects, opts), nil
+}
+
+// LoadFS returns the resources of all the .yaml and .yml files of the directory, in the order of their names.
+// It works with an embed.FS
+func LoadFS(fsys fs.FS, dir string, opts Options) ([]types.Resource, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var objects []client.Object
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := Decode(bytes.NewReader(content), opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		objects = append(objects, decoded...)
+	}
+	return FromObjects(objects, opts), nil
+}
+
+// LoadDir returns the resources of all the .yaml and .yml files of the directory
+func LoadDir(dir string, opts Options) ([]types.Resource, error) {
+	return LoadFS(os.DirFS(dir), ".", opts)
+}
+
+// Decode returns the objects of the multi-document YAML, the empty documents are skipped
+func Decode(reader io.Reader, opts Options) ([]client.Object, error) {
+	if opts.Values != nil {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New("manifest").Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, err
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, opts.Values); err != nil {
+			return nil, err
+		}
+		reader = buf
+	}
+	var deserializer runtime.Decoder
+	if opts.Scheme != nil {
+		deserializer = serializer.NewCodecFactory(opts.Scheme).UniversalDeserializer()
+	}
+	var objects []client.Object
+	yamlReader := yaml.NewYAMLReader(bufio.NewReader(reader))
+	for {
+		document, err := yamlReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := yaml.ToJSON(document)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 || string(bytes.TrimSpace(data)) == "null" {
+			continue
+		}
+		obj, err := decodeObject(deserializer, data)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func decodeObject(deserializer runtime.Decoder, data []byte) (client.Object, error) {
+	if deserializer != nil {
+		obj, _, err := deserializer.Decode(data, nil, nil)
+		if err == nil {
+			if object, ok := obj.(client.Object); ok {
+				return object, nil
+			}
+		} else if !runtime.IsNotRegisteredError(err) {
+			return nil, err
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	if obj.GetName() == "" {
+		return nil, fmt.Errorf("%s without name", obj.GetKind())
+	}
+	return obj, nil
+}
+
+// FromObjects wraps the objects as resources, ordered by InstallOrder.
+// The kinds with a resource of this module use it (e.g. a Deployment waits to be available), the other kinds are wrapped in Object
+func FromObjects(objects []client.Object, opts Options) []types.Resource {
+	rank := map[string]int{}
+	for idx, kind := range InstallOrder {
+		rank[kind] = idx + 1
+	}
+	kindOf := func(obj client.Object) string {
+		if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			return kind
+		}
+		if opts.Scheme != nil {
+			if kinds, _, err := opts.Scheme.ObjectKinds(obj); err == nil && len(kinds) > 0 {
+				return kinds[0].Kind
+			}
+		}
+		return ""
+	}
+	groupOf := func(kind string) int {
+		if r, ok := rank[kind]; ok {
+			return r
+		}
+		return len(InstallOrder) + 1
+	}
+	sorted := make([]client.Object, len(objects))
+	copy(sorted, objects)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return groupOf(kindOf(sorted[a])) < groupOf(kindOf(sorted[b]))
+	})
+
+	clusterScoped := map[string]bool{}
+	for _, kind := range append(append([]string{}, ClusterScopedKinds...), opts.ClusterScopedKinds...) {
+		clusterScoped[kind] = true
+	}
+	resources := make([]types.Resource, 0, len(sorted))
+	for _, obj := range sorted {
+		kind := kindOf(obj)
+		res := wrap(obj, kind, clusterScoped[kind])
+		if group, ok := res.(types.ConvergedGroupInterface); ok {
+			group.SetConvergedGroupID(groupOf(kind) + opts.GroupOffset)
+		}
+		resources = append(resources, res)
+	}
+	return resources
+}
+
+// wrap returns the resource of this module for the known kinds
+func wrap(obj client.Object, kind string, clusterScoped bool) types.Resource {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return apps.NewDeployment(o)
+	case *appsv1.StatefulSet:
+		return apps.NewStatefulSet(o)
+	case *batchv1.Job:
+		return batch.NewJob(o)
+	case *batchv1.CronJob:
+		return batch.NewCronJob(o.Name, o)
+	case *v1.ConfigMap:
+		return core.NewConfigMap(o)
+	case *v1.Secret:
+		return core.NewSecret(o)
+	case *v1.Service:
+		return core.NewService(o)
+	case *v1.ServiceAccount:
+		return core.NewServiceAccount(o)
+	case *v1.PersistentVolumeClaim:
+		return core.NewPersistentVolumeClaim(o)
+	case *rbacv1.Role:
+		return rbac.NewRole(o)
+	case *rbacv1.RoleBinding:
+		return rbac.NewRoleBinding(o)
+	case *rbacv1.ClusterRole:
+		return rbac.NewClusterRole(o)
+	case *rbacv1.ClusterRoleBinding:
+		return rbac.NewClusterRoleBinding(o)
+	case *networkingv1.NetworkPolicy:
+		return networking.NewNetworkPolicy(o)
+	case *policyv1.PodDisruptionBudget:
+		return policy.NewPodDisruptionBudget(o)
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		return autoscaling.NewHorizontalPodAutoscaler(o)
+	}
+	return NewObject(strings.ToLower(kind), obj, clusterScoped)
+}
+
+// Object is a resource of any kind, typed or unstructured, without readiness check
+type Object struct {
+	*common.SimpleResource[client.Object]
+	clusterScoped bool
+}
+
+func NewObject(typeName string, obj client.Object, clusterScoped bool) *Object {
+	return &Object{SimpleResource: common.NewSimpleResource(typeName, obj), clusterScoped: clusterScoped}
+}
+
+func (o *Object) ClusterScoped() bool {
+	return o.clusterScoped
+}
+
+// Runtime returns an empty object of the same kind, an unstructured object keep its kind
+func (o *Object) Runtime() client.Object {
+	if u, ok := o.Obj.(*unstructured.Unstructured); ok {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(u.GroupVersionKind())
+		return obj
+	}
+	return o.SimpleResource.Runtime()
+}
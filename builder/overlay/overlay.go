@@ -0,0 +1,260 @@
+package overlay
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ImageOverride replace the image of the containers using the image Name, whatever its tag or digest
+type ImageOverride struct {
+	// Name of the image to replace, e.g. nginx or quay.io/org/app
+	Name string `json:"name"`
+	// NewName replace the registry and the repository, e.g. mirror.example.com/nginx
+	// +optional
+	NewName string `json:"newName,omitempty"`
+	// NewTag replace the tag, the digest is removed
+	// +optional
+	NewTag string `json:"newTag,omitempty"`
+	// Digest pin the image, the tag is removed
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// Overlay customize the objects of the formation, e.g. per environment, with the same fields as a kustomization.
+// It can be embedded in a CR or loaded from a file
+type Overlay struct {
+	// NamePrefix is added to the name of every object, the references between the objects are renamed
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+	// NameSuffix is added to the name of every object, the references between the objects are renamed
+	// +optional
+	NameSuffix string `json:"nameSuffix,omitempty"`
+	// CommonLabels are added to every object and pod template. The selectors are not changed, they are immutable on the workloads
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to every object and pod template
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// +optional
+	Images []ImageOverride `json:"images,omitempty"`
+	// Patches are applied first, their target use the names before the prefix and the suffix
+	// +optional
+	Patches []types.Patch `json:"patches,omitempty"`
+
+	// applied is set by Apply, the names would otherwise be prefixed and suffixed twice
+	applied bool
+}
+
+func (in *ImageOverride) DeepCopyInto(t *ImageOverride) {
+	*t = *in
+}
+
+func (in *ImageOverride) DeepCopy() *ImageOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copy the overlay, the copy is not applied yet
+func (in *Overlay) DeepCopyInto(t *Overlay) {
+	*t = *in
+	t.applied = false
+	if in.CommonLabels != nil {
+		t.CommonLabels = utils.MergeMap(make(map[string]string, len(in.CommonLabels)), in.CommonLabels)
+	}
+	if in.CommonAnnotations != nil {
+		t.CommonAnnotations = utils.MergeMap(make(map[string]string, len(in.CommonAnnotations)), in.CommonAnnotations)
+	}
+	if in.Images != nil {
+		t.Images = make([]ImageOverride, len(in.Images))
+		copy(t.Images, in.Images)
+	}
+	if in.Patches != nil {
+		t.Patches = make([]types.Patch, len(in.Patches))
+		copy(t.Patches, in.Patches)
+	}
+}
+
+func (in *Overlay) DeepCopy() *Overlay {
+	if in == nil {
+		return nil
+	}
+	out := new(Overlay)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// Load decode an overlay in YAML or JSON
+func Load(reader io.Reader) (*Overlay, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	overlay := &Overlay{}
+	if err := yaml.UnmarshalStrict(content, overlay); err != nil {
+		return nil, err
+	}
+	return overlay, nil
+}
+
+// LoadFS load the overlay file, it works with an embed.FS
+func LoadFS(fsys fs.FS, name string) (*Overlay, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	overlay, err := Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return overlay, nil
+}
+
+// LoadFile load the overlay file
+func LoadFile(name string) (*Overlay, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	overlay, err := Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return overlay, nil
+}
+
+// Apply customize in place the objects of the resources, in order: the patches, the images,
+// the common labels and annotations, then the names. A patch without matching resource is an error.
+// The object shared by the resources is customized, they must implement types.SharedObject (e.g. the SimpleResource),
+// the changes would otherwise be lost. Apply can only be called once, use a DeepCopy of the overlay for other resources
+func (o *Overlay) Apply(resources []types.Resource) error {
+	if o.applied {
+		return fmt.Errorf("overlay already applied")
+	}
+	objects := make([]client.Object, len(resources))
+	for idx, res := range resources {
+		shared, ok := res.(types.SharedObject)
+		if !ok || shared.Object() == nil {
+			return fmt.Errorf("%s/%s: the overlay can only be applied to resources sharing their object", res.Type(), res.Name())
+		}
+		objects[idx] = shared.Object()
+	}
+	o.applied = true
+
+	for _, patch := range o.Patches {
+		matched := false
+		for idx, res := range resources {
+			match, err := utils.PatchTargetMatch(patch.Target, res.Type(), objects[idx].GetName())
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			if err := utils.ApplyPatch(objects[idx], patch); err != nil {
+				return err
+			}
+			matched = true
+		}
+		if !matched {
+			return fmt.Errorf("patch target %s does not match any resource", patch.Target)
+		}
+	}
+
+	for _, obj := range objects {
+		if err := o.applyImages(obj); err != nil {
+			return fmt.Errorf("%s: %w", obj.GetName(), err)
+		}
+		o.applyMetadata(obj)
+	}
+
+	if o.NamePrefix == "" && o.NameSuffix == "" {
+		return nil
+	}
+	renamed := map[string]string{}
+	for idx, res := range resources {
+		name := o.NamePrefix + objects[idx].GetName() + o.NameSuffix
+		renamed[res.Type()+"/"+objects[idx].GetName()] = name
+		objects[idx].SetName(name)
+	}
+	for _, obj := range objects {
+		utils.RenameReferences(obj, func(kind, name string) string {
+			if newName, ok := renamed[kind+"/"+name]; ok {
+				return newName
+			}
+			return name
+		})
+	}
+	return nil
+}
+
+func (o *Overlay) applyImages(obj client.Object) error {
+	spec := utils.PodSpec(obj)
+	if spec == nil || len(o.Images) == 0 {
+		return nil
+	}
+	for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+		for idx := range containers {
+			image, err := o.rewriteImage(containers[idx].Image)
+			if err != nil {
+				return fmt.Errorf("container %s: %w", containers[idx].Name, err)
+			}
+			containers[idx].Image = image
+		}
+	}
+	return nil
+}
+
+// rewriteImage returns the image after the first matching override
+func (o *Overlay) rewriteImage(image string) (string, error) {
+	ref, err := utils.ParseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+	for _, override := range o.Images {
+		name, err := utils.ParseImageRef(override.Name)
+		if err != nil {
+			return "", fmt.Errorf("image override %s: %w", override.Name, err)
+		}
+		if name.Name() != ref.Name() {
+			continue
+		}
+		if override.NewName != "" {
+			newName, err := utils.ParseImageRef(override.NewName)
+			if err != nil {
+				return "", fmt.Errorf("image override %s: %w", override.Name, err)
+			}
+			ref.Registry, ref.Repository = newName.Registry, newName.Repository
+		}
+		if override.NewTag != "" {
+			ref.Tag, ref.Digest = override.NewTag, ""
+		}
+		if override.Digest != "" {
+			ref.Tag, ref.Digest = "", override.Digest
+		}
+		return ref.String(), nil
+	}
+	return image, nil
+}
+
+func (o *Overlay) applyMetadata(obj client.Object) {
+	obj.SetLabels(utils.MergeMap(obj.GetLabels(), o.CommonLabels))
+	obj.SetAnnotations(utils.MergeMap(obj.GetAnnotations(), o.CommonAnnotations))
+	if template := utils.PodTemplate(obj); template != nil {
+		template.Labels = utils.MergeMap(template.Labels, o.CommonLabels)
+		template.Annotations = utils.MergeMap(template.Annotations, o.CommonAnnotations)
+	}
+}
@@ -1,11 +1,14 @@
 package apps
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/davidboxer/formation/builder"
 	"github.com/davidboxer/formation/types"
 	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -13,6 +16,10 @@ type PodBuilder struct {
 	*types.ConvergedGroup
 	builder.Builder
 	Spec *v1.PodSpec
+	// sidecars are the names of the regular containers marked as sidecars
+	sidecars []string
+	// externalReferences are the <type>/<name> of the objects referenced by the pod that are not part of the formation
+	externalReferences []string
 }
 type VolumeTemplateBuilder interface {
 	HandleTemplate(containerName string, containerVolume v1.VolumeMount, pvc v1.PersistentVolumeClaim)
@@ -168,6 +175,112 @@ func (builder *PodBuilder) ResourcesName() []string {
 	return names
 }
 
+// GetPodBuilder returns the PodBuilder, it is promoted to the workload builders embedding it
+func (builder *PodBuilder) GetPodBuilder() *PodBuilder {
+	return builder
+}
+
+// AddExternalReferences list the <type>/<name> of objects referenced by the pod that are not part of the formation,
+// e.g. persistentvolumeclaim/data. The validation of the formation does not report them as dangling.
+// They are passed to the resource, they are not written to the object
+func (builder *PodBuilder) AddExternalReferences(refs ...string) *PodBuilder {
+	for _, ref := range refs {
+		found := false
+		for _, existing := range builder.externalReferences {
+			if strings.EqualFold(existing, ref) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			builder.externalReferences = append(builder.externalReferences, ref)
+		}
+	}
+	return builder
+}
+
+// ExternalReferences returns the objects referenced by the pod that are not part of the formation
+func (builder *PodBuilder) ExternalReferences() []string {
+	return builder.externalReferences
+}
+
+// SetSidecar mark the regular containers as sidecars, e.g. for the visibility of LinkVolumes.
+// The native sidecars, init containers with restartPolicy Always, do not need to be marked
+func (builder *PodBuilder) SetSidecar(containerNames ...string) *PodBuilder {
+	for _, name := range containerNames {
+		found := false
+		for _, sidecar := range builder.sidecars {
+			if sidecar == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			builder.sidecars = append(builder.sidecars, name)
+		}
+	}
+	return builder
+}
+
+// Sidecars returns the name of the regular containers marked as sidecars and of the native sidecars
+func (builder *PodBuilder) Sidecars() []string {
+	var names []string
+	for _, container := range builder.Spec.InitContainers {
+		if isNativeSidecar(container) {
+			names = append(names, container.Name)
+		}
+	}
+	return append(names, builder.sidecars...)
+}
+
+func isNativeSidecar(container v1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways
+}
+
+// ContainerTypes returns the type of each container of the pod by name
+func (builder *PodBuilder) ContainerTypes() map[string]types.ContainerType {
+	containerTypes := map[string]types.ContainerType{}
+	for _, container := range builder.Spec.InitContainers {
+		containerTypes[container.Name] = types.ContainerTypeInit
+		if isNativeSidecar(container) {
+			containerTypes[container.Name] = types.ContainerTypeSidecar
+		}
+	}
+	for _, container := range builder.Spec.Containers {
+		containerTypes[container.Name] = types.ContainerTypeRegular
+	}
+	for _, name := range builder.sidecars {
+		if containerTypes[name] == types.ContainerTypeRegular {
+			containerTypes[name] = types.ContainerTypeSidecar
+		}
+	}
+	return containerTypes
+}
+
+// SetPodSecurityContext Set the security context of the pod
+func (builder *PodBuilder) SetPodSecurityContext(securityContext v1.PodSecurityContext) *PodBuilder {
+	builder.Spec.SecurityContext = securityContext.DeepCopy()
+	return builder
+}
+
+// SetSecurityProfile Set the security context of the pod and of all its containers for the Pod Security Standards profile.
+// The ids left unset are assigned by the platform, leave them unset for the OpenShift restricted-v2 SCC
+func (builder *PodBuilder) SetSecurityProfile(profile types.SecurityProfile, ids types.SecurityIDs) *PodBuilder {
+	builder.Spec.SecurityContext = utils.PodSecurityContext(profile, ids)
+	for index := range builder.Spec.InitContainers {
+		ToContainerBuilder(&builder.Spec.InitContainers[index]).SetSecurityProfile(profile)
+	}
+	for index := range builder.Spec.Containers {
+		ToContainerBuilder(&builder.Spec.Containers[index]).SetSecurityProfile(profile)
+	}
+	return builder
+}
+
+// CheckSecurity reports which Pod Security Standards profile the pod satisfies
+func (builder *PodBuilder) CheckSecurity() utils.PodSecurityReport {
+	return utils.CheckPodSecurity(builder.Spec)
+}
+
 // AddResourceRequirements add resource requirements to the container
 func (builder *PodBuilder) AddResourceRequirements(containerName string, resourceRequirements v1.ResourceRequirements) {
 	container := builder.GetContainer(containerName)
@@ -225,22 +338,58 @@ func (builder *PodBuilder) AddImagePullSecrets(secretNames ...string) {
 	builder.Spec.ImagePullSecrets = utils.MergeLocalObjectReference(builder.Spec.ImagePullSecrets, secretReference)
 }
 
-// SetStartupProbeConfiguration set the startup probe configuration for the container
+// SetStartupProbeConfiguration set the startup probe configuration for the container.
+// An unknown container or an invalid configuration is logged, use TrySetStartupProbeConfiguration to get the error
 func (builder *PodBuilder) SetStartupProbeConfiguration(containerName string, config types.ProbeConfiguration) {
+	if err := builder.TrySetStartupProbeConfiguration(containerName, config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
+}
+
+// TrySetStartupProbeConfiguration set the startup probe configuration for the container,
+// an error is returned if the container does not exist or the configuration is invalid
+func (builder *PodBuilder) TrySetStartupProbeConfiguration(containerName string, config types.ProbeConfiguration) error {
 	container := builder.GetContainer(containerName)
-	ApplyProbeConfiguration(container.StartupProbe, config)
+	if container == nil {
+		return fmt.Errorf("container %s not found", containerName)
+	}
+	return ToContainerBuilder(container).TrySetStartupProbeConfiguration(config)
 }
 
-// SetLivenessProbeConfiguration set the liveness probe configuration for the container
+// SetLivenessProbeConfiguration set the liveness probe configuration for the container.
+// An unknown container or an invalid configuration is logged, use TrySetLivenessProbeConfiguration to get the error
 func (builder *PodBuilder) SetLivenessProbeConfiguration(containerName string, config types.ProbeConfiguration) {
+	if err := builder.TrySetLivenessProbeConfiguration(containerName, config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
+}
+
+// TrySetLivenessProbeConfiguration set the liveness probe configuration for the container,
+// an error is returned if the container does not exist or the configuration is invalid
+func (builder *PodBuilder) TrySetLivenessProbeConfiguration(containerName string, config types.ProbeConfiguration) error {
 	container := builder.GetContainer(containerName)
-	ApplyProbeConfiguration(container.LivenessProbe, config)
+	if container == nil {
+		return fmt.Errorf("container %s not found", containerName)
+	}
+	return ToContainerBuilder(container).TrySetLivenessProbeConfiguration(config)
 }
 
-// SetReadinessProbeConfiguration set the readiness probe configuration for the container
+// SetReadinessProbeConfiguration set the readiness probe configuration for the container.
+// An unknown container or an invalid configuration is logged, use TrySetReadinessProbeConfiguration to get the error
 func (builder *PodBuilder) SetReadinessProbeConfiguration(containerName string, config types.ProbeConfiguration) {
+	if err := builder.TrySetReadinessProbeConfiguration(containerName, config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
+}
+
+// TrySetReadinessProbeConfiguration set the readiness probe configuration for the container,
+// an error is returned if the container does not exist or the configuration is invalid
+func (builder *PodBuilder) TrySetReadinessProbeConfiguration(containerName string, config types.ProbeConfiguration) error {
 	container := builder.GetContainer(containerName)
-	ApplyProbeConfiguration(container.ReadinessProbe, config)
+	if container == nil {
+		return fmt.Errorf("container %s not found", containerName)
+	}
+	return ToContainerBuilder(container).TrySetReadinessProbeConfiguration(config)
 }
 
 // SetServiceAccountName set the service account of the pod
@@ -0,0 +1,82 @@
+package apps
+
+import (
+	"github.com/davidboxer/formation/resources/autoscaling"
+	"github.com/davidboxer/formation/resources/policy"
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Companions hold the resources that are created alongside a workload (Deployment, StatefulSet).
+// They share the name, the selector and the converged group of the workload.
+type Companions struct {
+	PodDisruptionBudget     *policyv1.PodDisruptionBudget
+	HorizontalPodAutoscaler *autoscalingv2.HorizontalPodAutoscaler
+}
+
+// SetPodDisruptionBudget Set the PodDisruptionBudget of the workload.
+// Only one of minAvailable and maxUnavailable should be set
+func (c *Companions) SetPodDisruptionBudget(minAvailable, maxUnavailable *intstr.IntOrString) {
+	c.PodDisruptionBudget = &policyv1.PodDisruptionBudget{
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
+
+// SetHorizontalPodAutoscaler Set the HorizontalPodAutoscaler of the workload.
+// Once set, the replicas of the workload are no longer enforced by the controller.
+func (c *Companions) SetHorizontalPodAutoscaler(minReplicas *int32, maxReplicas int32, metrics ...autoscalingv2.MetricSpec) {
+	c.HorizontalPodAutoscaler = &autoscalingv2.HorizontalPodAutoscaler{
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			MinReplicas: minReplicas,
+			MaxReplicas: maxReplicas,
+			Metrics:     metrics,
+		},
+	}
+}
+
+// HasHorizontalPodAutoscaler returns true if the replicas of the workload are managed by a HorizontalPodAutoscaler
+func (c *Companions) HasHorizontalPodAutoscaler() bool {
+	return c.HorizontalPodAutoscaler != nil
+}
+
+// toResources returns the companions resources of the workload
+func (c *Companions) toResources(name, kind string, labels map[string]string, selector *metav1.LabelSelector, groupID int) []types.Resource {
+	var resources []types.Resource
+	if c.PodDisruptionBudget != nil {
+		pdb := c.PodDisruptionBudget.DeepCopy()
+		pdb.Name = name
+		pdb.Labels = utils.MergeMap(nil, labels)
+		pdb.Spec.Selector = selector.DeepCopy()
+		res := policy.NewPodDisruptionBudget(pdb)
+		res.SetConvergedGroupID(groupID)
+		resources = append(resources, res)
+	}
+	if c.HorizontalPodAutoscaler != nil {
+		hpa := c.HorizontalPodAutoscaler.DeepCopy()
+		hpa.Name = name
+		hpa.Labels = utils.MergeMap(nil, labels)
+		hpa.Spec.ScaleTargetRef = autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       kind,
+			Name:       name,
+		}
+		res := autoscaling.NewHorizontalPodAutoscaler(hpa)
+		res.SetConvergedGroupID(groupID)
+		resources = append(resources, res)
+	}
+	return resources
+}
+
+func (c *Companions) deepCopy() Companions {
+	return Companions{
+		PodDisruptionBudget:     c.PodDisruptionBudget.DeepCopy(),
+		HorizontalPodAutoscaler: c.HorizontalPodAutoscaler.DeepCopy(),
+	}
+}
@@ -1,11 +1,13 @@
 package apps
 
 import (
+	"fmt"
 	"github.com/davidboxer/formation/types"
 	"strconv"
 	"strings"
 
 	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -20,16 +22,7 @@ func NewContainer(name string) *ContainerBuilder {
 			TerminationMessagePath:   "/dev/termination-log",
 			TerminationMessagePolicy: v1.TerminationMessageReadFile,
 			ImagePullPolicy:          v1.PullIfNotPresent,
-			SecurityContext: &v1.SecurityContext{
-				AllowPrivilegeEscalation: utils.ToPointer(false),
-				Capabilities: &v1.Capabilities{
-					Drop: []v1.Capability{"ALL"},
-				},
-				SeccompProfile: &v1.SeccompProfile{
-					Type: "RuntimeDefault",
-				},
-				RunAsNonRoot: utils.ToPointer(true),
-			},
+			SecurityContext:          utils.ContainerSecurityContext(types.SecurityProfileRestricted),
 		},
 	}
 }
@@ -54,21 +47,81 @@ func (c *ContainerBuilder) SetImage(image string) *ContainerBuilder {
 	return c
 }
 
-// SetReadinessProbeConfiguration Set the readiness probe's configuration for the container
+// SetReadinessProbeConfiguration Set the readiness probe's configuration for the container, see ProbeFromConfiguration.
+// An invalid configuration is logged and the probe is unchanged, use TrySetReadinessProbeConfiguration to get the error
 func (c *ContainerBuilder) SetReadinessProbeConfiguration(config types.ProbeConfiguration) *ContainerBuilder {
-	ApplyProbeConfiguration(c.ReadinessProbe, config)
+	if err := c.TrySetReadinessProbeConfiguration(config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
 	return c
 }
 
-// SetLivenessProbeConfiguration Set the liveness probe's configuration for the container
+// TrySetReadinessProbeConfiguration Set the readiness probe's configuration for the container, see ProbeFromConfiguration.
+// The probe is unchanged if the configuration is invalid
+func (c *ContainerBuilder) TrySetReadinessProbeConfiguration(config types.ProbeConfiguration) error {
+	probe, err := ProbeFromConfiguration(c.ReadinessProbe, config)
+	if err != nil {
+		return fmt.Errorf("container %s: readiness probe: %w", c.Name, err)
+	}
+	c.ReadinessProbe = probe
+	return nil
+}
+
+// SetLivenessProbeConfiguration Set the liveness probe's configuration for the container, see ProbeFromConfiguration.
+// An invalid configuration is logged and the probe is unchanged, use TrySetLivenessProbeConfiguration to get the error
 func (c *ContainerBuilder) SetLivenessProbeConfiguration(config types.ProbeConfiguration) *ContainerBuilder {
-	ApplyProbeConfiguration(c.LivenessProbe, config)
+	if err := c.TrySetLivenessProbeConfiguration(config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
 	return c
 }
 
-// SetStartupProbeConfiguration Set the startup probe's configuration for the container
+// TrySetLivenessProbeConfiguration Set the liveness probe's configuration for the container, see ProbeFromConfiguration.
+// The probe is unchanged if the configuration is invalid
+func (c *ContainerBuilder) TrySetLivenessProbeConfiguration(config types.ProbeConfiguration) error {
+	probe, err := ProbeFromConfiguration(c.LivenessProbe, config)
+	if err != nil {
+		return fmt.Errorf("container %s: liveness probe: %w", c.Name, err)
+	}
+	c.LivenessProbe = probe
+	return nil
+}
+
+// SetStartupProbeConfiguration Set the startup probe's configuration for the container, see ProbeFromConfiguration.
+// An invalid configuration is logged and the probe is unchanged, use TrySetStartupProbeConfiguration to get the error
 func (c *ContainerBuilder) SetStartupProbeConfiguration(config types.ProbeConfiguration) *ContainerBuilder {
-	ApplyProbeConfiguration(c.StartupProbe, config)
+	if err := c.TrySetStartupProbeConfiguration(config); err != nil {
+		log.Error().Err(err).Msg("invalid probe configuration")
+	}
+	return c
+}
+
+// TrySetStartupProbeConfiguration Set the startup probe's configuration for the container, see ProbeFromConfiguration.
+// The probe is unchanged if the configuration is invalid
+func (c *ContainerBuilder) TrySetStartupProbeConfiguration(config types.ProbeConfiguration) error {
+	probe, err := ProbeFromConfiguration(c.StartupProbe, config)
+	if err != nil {
+		return fmt.Errorf("container %s: startup probe: %w", c.Name, err)
+	}
+	c.StartupProbe = probe
+	return nil
+}
+
+// SetReadinessProbe Set the readiness probe of the container, e.g. NewHTTPGetProbe("/ready", intstr.FromString("http"), "")
+func (c *ContainerBuilder) SetReadinessProbe(probe *v1.Probe) *ContainerBuilder {
+	c.ReadinessProbe = probe
+	return c
+}
+
+// SetLivenessProbe Set the liveness probe of the container
+func (c *ContainerBuilder) SetLivenessProbe(probe *v1.Probe) *ContainerBuilder {
+	c.LivenessProbe = probe
+	return c
+}
+
+// SetStartupProbe Set the startup probe of the container
+func (c *ContainerBuilder) SetStartupProbe(probe *v1.Probe) *ContainerBuilder {
+	c.StartupProbe = probe
 	return c
 }
 
@@ -77,6 +130,12 @@ func (c *ContainerBuilder) SetSecurityContext(securityContext v1.SecurityContext
 	return c
 }
 
+// SetSecurityProfile Set the security context of the container for the Pod Security Standards profile
+func (c *ContainerBuilder) SetSecurityProfile(profile types.SecurityProfile) *ContainerBuilder {
+	c.SecurityContext = utils.ContainerSecurityContext(profile)
+	return c
+}
+
 func (c *ContainerBuilder) SetImagePullPolicy(imagePullPolicy v1.PullPolicy) *ContainerBuilder {
 	c.ImagePullPolicy = imagePullPolicy
 	return c
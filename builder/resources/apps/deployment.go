@@ -11,6 +11,7 @@ import (
 
 type DeploymentBuilder struct {
 	*PodBuilder
+	Companions
 	Deployment *appsv1.Deployment
 }
 
@@ -55,8 +56,11 @@ func (d *DeploymentBuilder) DeepCopy() *DeploymentBuilder {
 				Object: deployCopy,
 				Name:   d.Name,
 			},
-			Spec: &deployCopy.Spec.Template.Spec,
+			Spec:               &deployCopy.Spec.Template.Spec,
+			sidecars:           append([]string(nil), d.sidecars...),
+			externalReferences: append([]string(nil), d.externalReferences...),
 		},
+		Companions: d.Companions.deepCopy(),
 		Deployment: deployCopy,
 	}
 }
@@ -98,12 +102,26 @@ func (d *DeploymentBuilder) SetReplicas(replicas int32) {
 	d.Deployment.Spec.Replicas = &replicas
 }
 
+// PodLabels returns the labels of the pod template
+func (d *DeploymentBuilder) PodLabels() map[string]string {
+	return d.Deployment.Spec.Template.Labels
+}
+
 // ToResource Create the interface to the Formation controller
 func (builder *DeploymentBuilder) ToResource() types.Resource {
 	builder.Deployment.Labels = builder.Labels()
 	builder.Deployment.Annotations = builder.Annotations()
 	builder.Deployment.Name = builder.Name
 	a := apps.NewDeployment(builder.Deployment)
+	a.IgnoreReplicas = builder.HasHorizontalPodAutoscaler()
+	a.External = builder.ExternalReferences()
 	a.SetConvergedGroupID(builder.GetConvergedGroupID())
 	return a
 }
+
+// ToResources Create the Deployment and its companions (PodDisruptionBudget, HorizontalPodAutoscaler)
+func (builder *DeploymentBuilder) ToResources() []types.Resource {
+	resources := []types.Resource{builder.ToResource()}
+	return append(resources, builder.Companions.toResources(builder.Name, "Deployment", builder.Deployment.Labels,
+		builder.Deployment.Spec.Selector, builder.GetConvergedGroupID())...)
+}
@@ -0,0 +1,209 @@
+package apps
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// EnvFromField Create an environment variable from a field of the pod, e.g. metadata.name or status.podIP
+func EnvFromField(name, fieldPath string) v1.EnvVar {
+	return v1.EnvVar{
+		Name:      name,
+		ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{APIVersion: "v1", FieldPath: fieldPath}},
+	}
+}
+
+// EnvPodName Create an environment variable containing the name of the pod
+func EnvPodName(name string) v1.EnvVar {
+	return EnvFromField(name, "metadata.name")
+}
+
+// EnvPodNamespace Create an environment variable containing the namespace of the pod
+func EnvPodNamespace(name string) v1.EnvVar {
+	return EnvFromField(name, "metadata.namespace")
+}
+
+// EnvNodeName Create an environment variable containing the name of the node running the pod
+func EnvNodeName(name string) v1.EnvVar {
+	return EnvFromField(name, "spec.nodeName")
+}
+
+// EnvPodIP Create an environment variable containing the IP of the pod
+func EnvPodIP(name string) v1.EnvVar {
+	return EnvFromField(name, "status.podIP")
+}
+
+// EnvFromResource Create an environment variable containing a request or a limit of a container, e.g. limits.memory.
+// An empty containerName refer to the container the variable is added to. The divisor is optional
+func EnvFromResource(name, containerName, resourceName string, divisor *resource.Quantity) v1.EnvVar {
+	selector := &v1.ResourceFieldSelector{ContainerName: containerName, Resource: resourceName}
+	if divisor != nil {
+		selector.Divisor = divisor.DeepCopy()
+	}
+	return v1.EnvVar{Name: name, ValueFrom: &v1.EnvVarSource{ResourceFieldRef: selector}}
+}
+
+// EnvFromConfigMapKey Create an environment variable from a key of a ConfigMap of the formation.
+// An error is returned if the ConfigMap does not contain the key
+func EnvFromConfigMapKey(name string, configMap *v1.ConfigMap, key string) (v1.EnvVar, error) {
+	_, inData := configMap.Data[key]
+	_, inBinaryData := configMap.BinaryData[key]
+	if !inData && !inBinaryData {
+		return v1.EnvVar{}, fmt.Errorf("configmap %s does not contain the key %s", configMap.Name, key)
+	}
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: configMap.Name},
+			Key:                  key,
+		}},
+	}, nil
+}
+
+// EnvFromSecretKey Create an environment variable from a key of a Secret of the formation.
+// An error is returned if the Secret does not contain the key, the keys of a generated Secret are only known by
+// its generators, use EnvFromSecretKeyRef for them
+func EnvFromSecretKey(name string, secret *v1.Secret, key string) (v1.EnvVar, error) {
+	_, inData := secret.Data[key]
+	_, inStringData := secret.StringData[key]
+	if !inData && !inStringData {
+		return v1.EnvVar{}, fmt.Errorf("secret %s does not contain the key %s", secret.Name, key)
+	}
+	return EnvFromSecretKeyRef(name, secret.Name, key), nil
+}
+
+// EnvFromSecretKeyRef Create an environment variable from a key of a Secret without checking the key
+func EnvFromSecretKeyRef(name, secretName, key string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+			Key:                  key,
+		}},
+	}
+}
+
+// envReference match $(VAR) and $$ (escaped $)
+var envReference = regexp.MustCompile(`\$\$|\$\(([A-Za-z_][A-Za-z0-9_.-]*)\)`)
+
+// envReferences returns the names of the variables referenced by the value with $(VAR)
+func envReferences(value string) []string {
+	var names []string
+	for _, match := range envReference.FindAllStringSubmatch(value, -1) {
+		if match[1] != "" {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// envDependencies returns the index of the variables referenced with $(VAR) by each variable.
+// A name defined several times resolve to its first definition, as Kubernetes expand a reference with the
+// value defined before. A variable referencing its own name is not a dependency, the reference use the previous
+// definition if any. The variables not defined in the list (e.g. from envFrom) are ignored
+func envDependencies(envs []v1.EnvVar) [][]int {
+	first := map[string]int{}
+	for idx, env := range envs {
+		if _, ok := first[env.Name]; !ok {
+			first[env.Name] = idx
+		}
+	}
+	dependencies := make([][]int, len(envs))
+	for idx, env := range envs {
+		for _, name := range envReferences(env.Value) {
+			if ref, ok := first[name]; ok && name != env.Name {
+				dependencies[idx] = append(dependencies[idx], ref)
+			}
+		}
+	}
+	return dependencies
+}
+
+// ValidateEnvOrder returns an error if a variable reference with $(VAR) a variable defined after it.
+// Kubernetes only expand the variables defined before, the reference would be left as is.
+// The self references and the variables not defined in the list (e.g. from envFrom) are not checked
+func ValidateEnvOrder(envs []v1.EnvVar) error {
+	for idx, dependencies := range envDependencies(envs) {
+		for _, ref := range dependencies {
+			if ref > idx {
+				return fmt.Errorf("environment variable %s reference %s which is defined after it", envs[idx].Name, envs[ref].Name)
+			}
+		}
+	}
+	return nil
+}
+
+// SortEnv returns the variables ordered so each variable is defined before the variables referencing it with $(VAR),
+// the references are resolved as in ValidateEnvOrder. The order is kept when possible, an error is returned on a reference cycle
+func SortEnv(envs []v1.EnvVar) ([]v1.EnvVar, error) {
+	dependencies := envDependencies(envs)
+	sorted := make([]v1.EnvVar, 0, len(envs))
+	// 0 not visited, 1 in progress, 2 done
+	state := make([]int, len(envs))
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		switch state[idx] {
+		case 1:
+			return fmt.Errorf("environment variable %s has a reference cycle", envs[idx].Name)
+		case 2:
+			return nil
+		}
+		state[idx] = 1
+		for _, ref := range dependencies[idx] {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		state[idx] = 2
+		sorted = append(sorted, envs[idx])
+		return nil
+	}
+	for idx := range envs {
+		if err := visit(idx); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// RenderEnvTemplates returns the variables with their value rendered as a Go template over the values,
+// e.g. {{ .Database.Host }}:{{ .Database.Port }}. A missing key is an error
+func RenderEnvTemplates(values any, envs ...v1.EnvVar) ([]v1.EnvVar, error) {
+	rendered := make([]v1.EnvVar, 0, len(envs))
+	for _, env := range envs {
+		env = *env.DeepCopy()
+		if env.ValueFrom == nil && env.Value != "" {
+			tmpl, err := template.New(env.Name).Option("missingkey=error").Parse(env.Value)
+			if err != nil {
+				return nil, fmt.Errorf("environment variable %s: %w", env.Name, err)
+			}
+			buf := &bytes.Buffer{}
+			if err := tmpl.Execute(buf, values); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %w", env.Name, err)
+			}
+			env.Value = buf.String()
+		}
+		rendered = append(rendered, env)
+	}
+	return rendered, nil
+}
+
+// ValidateEnv returns an error if a variable of the container reference a variable defined after it
+func (c *ContainerBuilder) ValidateEnv() error {
+	return ValidateEnvOrder(c.Env)
+}
+
+// SortEnv Order the variables of the container so the variables referenced with $(VAR) are defined first
+func (c *ContainerBuilder) SortEnv() error {
+	sorted, err := SortEnv(c.Env)
+	if err != nil {
+		return err
+	}
+	c.Env = sorted
+	return nil
+}
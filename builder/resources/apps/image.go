@@ -0,0 +1,134 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/davidboxer/formation/utils"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ImageResolver resolve the tag of an image to its digest, e.g. by querying the registry
+type ImageResolver interface {
+	Resolve(ctx context.Context, ref utils.ImageRef) (string, error)
+}
+
+// StaticImageResolver resolve the images from a map of <registry>/<repository>:<tag> to digest, e.g. from a release manifest.
+// The keys are the images before the mirrors, they are normalized as ParseImageRef does, e.g. nginx:1.23 match docker.io/library/nginx:1.23
+type StaticImageResolver map[string]string
+
+func (s StaticImageResolver) Resolve(_ context.Context, ref utils.ImageRef) (string, error) {
+	ref.Digest = ""
+	if digest, ok := s[ref.String()]; ok {
+		return digest, nil
+	}
+	for image, digest := range s {
+		key, err := utils.ParseImageRef(image)
+		if err == nil && key.String() == ref.String() {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("no digest for image %s", ref.String())
+}
+
+// ImagePolicy rewrite the images of the pods consistently, e.g. for disconnected installs.
+// The overrides are applied first, then the mirrors, then the resolver. The pull secrets are added for the final images
+type ImagePolicy struct {
+	// Overrides replace the image of the containers by name, typically set from CR fields
+	Overrides map[string]string
+	// Mirrors replace a registry or a registry/repository prefix, e.g. docker.io: mirror.example.com/dockerhub.
+	// The longest matching prefix is used
+	Mirrors map[string]string
+	// Resolver if set, the images without digest are pinned to the digest of their tag.
+	// The resolver is given the image before the mirrors, the digest is the same in the mirror
+	Resolver ImageResolver
+	// PullSecrets add the secrets to the pods using an image of the registry or registry/repository prefix
+	PullSecrets map[string][]string
+}
+
+// Rewrite returns the image of the container after the overrides, the mirrors and the resolver.
+// The image is returned as is when none of them change it, it is only normalized when rewritten
+func (p *ImagePolicy) Rewrite(ctx context.Context, containerName, image string) (string, error) {
+	changed := false
+	if override, ok := p.Overrides[containerName]; ok && override != "" && override != image {
+		image = override
+		changed = true
+	}
+	ref, err := utils.ParseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+	source := ref
+	if prefix := longestPrefix(ref, p.Mirrors); prefix != "" {
+		ref = ref.WithPrefix(utils.NormalizeImagePrefix(prefix), p.Mirrors[prefix])
+		changed = true
+	}
+	if p.Resolver != nil && ref.Digest == "" {
+		digest, err := p.Resolver.Resolve(ctx, source)
+		if err != nil {
+			return "", err
+		}
+		ref.Digest = digest
+		changed = true
+	}
+	if !changed {
+		return image, nil
+	}
+	return ref.String(), nil
+}
+
+// pullSecrets returns the pull secrets of all the prefixes matching the image
+func (p *ImagePolicy) pullSecrets(image string) []string {
+	ref, err := utils.ParseImageRef(image)
+	if err != nil {
+		return nil
+	}
+	var secrets []string
+	for _, prefix := range utils.SortedKeys(p.PullSecrets) {
+		if ref.HasPrefix(utils.NormalizeImagePrefix(prefix)) {
+			secrets = append(secrets, p.PullSecrets[prefix]...)
+		}
+	}
+	return secrets
+}
+
+// longestPrefix returns the longest key of the prefixes matching the image, the keys are normalized with NormalizeImagePrefix
+func longestPrefix(ref utils.ImageRef, prefixes map[string]string) string {
+	keys := utils.SortedKeys(prefixes)
+	sort.SliceStable(keys, func(a, b int) bool {
+		return len(utils.NormalizeImagePrefix(keys[a])) > len(utils.NormalizeImagePrefix(keys[b]))
+	})
+	for _, prefix := range keys {
+		if ref.HasPrefix(utils.NormalizeImagePrefix(prefix)) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// ApplyImagePolicy rewrite the images of all the containers and add the matching pull secrets to the pod
+func (builder *PodBuilder) ApplyImagePolicy(ctx context.Context, policy *ImagePolicy) error {
+	var secrets []string
+	for _, containers := range [][]v1.Container{builder.Spec.InitContainers, builder.Spec.Containers} {
+		for idx := range containers {
+			container := &containers[idx]
+			image, err := policy.Rewrite(ctx, container.Name, container.Image)
+			if err != nil {
+				return fmt.Errorf("container %s: %w", container.Name, err)
+			}
+			container.Image = image
+			secrets = append(secrets, policy.pullSecrets(image)...)
+		}
+	}
+	if len(secrets) > 0 {
+		builder.AddImagePullSecrets(secrets...)
+	}
+	return nil
+}
+
+// SetImageRef Set the image of the container from a parsed reference
+func (c *ContainerBuilder) SetImageRef(ref utils.ImageRef) *ContainerBuilder {
+	c.Image = ref.String()
+	return c
+}
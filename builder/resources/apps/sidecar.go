@@ -0,0 +1,121 @@
+package apps
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Sidecar is a container injected in the pods of a formation, e.g. a log shipper or a metrics exporter.
+// The container brings its own env, ports and volume mounts, the Volumes are added to the pod.
+//
+// A native sidecar is an init container with restartPolicy Always, started before and stopped after the other containers.
+// It is inserted before the init containers of the pod, after the native sidecars already there.
+// It requires Kubernetes 1.29, or the SidecarContainers feature gate on 1.28, and is injected in every pod.
+// The other sidecars are injected as regular containers marked with SetSidecar, they are not injected in pods
+// that run to completion (restartPolicy other than Always, e.g. Jobs) as they would prevent the pod to complete.
+type Sidecar struct {
+	Container v1.Container
+	// Selector of the pods, using the pod part of the LinkVolumeData.Visibility format (e.g. api-*, [tier=web], !db).
+	// A pod is selected when it matches at least one entry and no exclusion
+	Selector []string
+	// Init inject the container as an init container, run to completion before the other containers
+	Init bool
+	// Native inject the container as a native sidecar, Init is ignored
+	Native bool
+	// Volumes added to the pod, a volume is skipped if the pod already has a volume with the same name
+	Volumes []v1.Volume
+}
+
+// SidecarInjector hold the sidecars registered once and inject them in all the matching pods of a formation
+type SidecarInjector struct {
+	sidecars []Sidecar
+}
+
+func NewSidecarInjector() *SidecarInjector {
+	return &SidecarInjector{}
+}
+
+// Register Add the sidecars to the injector
+func (i *SidecarInjector) Register(sidecars ...Sidecar) *SidecarInjector {
+	i.sidecars = append(i.sidecars, sidecars...)
+	return i
+}
+
+// Inject Add the registered sidecars to the matching builders, the objects that are not pod builders are ignored.
+// A sidecar is not injected twice in the same pod, it is safe to call Inject on every reconcile
+func (i *SidecarInjector) Inject(objects []any) error {
+	for _, sidecar := range i.sidecars {
+		var selectors []*Visibility
+		for _, value := range sidecar.Selector {
+			selector, err := ParseVisibility(value)
+			if err != nil {
+				return fmt.Errorf("sidecar %s: %w", sidecar.Container.Name, err)
+			}
+			selectors = append(selectors, selector)
+		}
+		for _, obj := range objects {
+			pod, ok := obj.(interface{ GetPodBuilder() *PodBuilder })
+			if !ok {
+				continue
+			}
+			podName, podLabels, _ := visibilityCandidates(obj)
+			if !sidecarMatch(selectors, podName, podLabels) {
+				continue
+			}
+			pod.GetPodBuilder().InjectSidecar(sidecar)
+		}
+	}
+	return nil
+}
+
+// InjectSidecar Add the sidecar container and its volumes to the pod, nothing is done if the pod already has the container
+func (builder *PodBuilder) InjectSidecar(sidecar Sidecar) *PodBuilder {
+	if builder.GetContainer(sidecar.Container.Name) != nil {
+		return builder
+	}
+	if !sidecar.Native && !sidecar.Init && builder.Spec.RestartPolicy != "" && builder.Spec.RestartPolicy != v1.RestartPolicyAlways {
+		log.Debug().Str("pod", builder.Builder.Name).Str("sidecar", sidecar.Container.Name).Msg("sidecar skipped, the pod run to completion")
+		return builder
+	}
+	for _, volume := range sidecar.Volumes {
+		if builder.GetVolume(volume.Name) == nil {
+			builder.Spec.Volumes = append(builder.Spec.Volumes, *volume.DeepCopy())
+		}
+	}
+	container := sidecar.Container.DeepCopy()
+	if sidecar.Native {
+		restartPolicy := v1.ContainerRestartPolicyAlways
+		container.RestartPolicy = &restartPolicy
+		syncContainer(container)
+		// The native sidecars are started before the init containers following them, they are inserted after the
+		// leading native sidecars so the init containers can use them, in the order of injection
+		idx := 0
+		for idx < len(builder.Spec.InitContainers) && isNativeSidecar(builder.Spec.InitContainers[idx]) {
+			idx++
+		}
+		builder.Spec.InitContainers = append(builder.Spec.InitContainers[:idx],
+			append([]v1.Container{*container}, builder.Spec.InitContainers[idx:]...)...)
+	} else if sidecar.Init {
+		builder.AddInitContainer(container)
+	} else {
+		builder.AddContainer(container)
+		builder.SetSidecar(container.Name)
+	}
+	return builder
+}
+
+func sidecarMatch(selectors []*Visibility, podName string, podLabels map[string]string) bool {
+	included := false
+	for _, selector := range selectors {
+		if !selector.MatchPod(podName, podLabels) {
+			continue
+		}
+		if selector.Exclude {
+			return false
+		}
+		included = true
+	}
+	return included
+}
@@ -0,0 +1,151 @@
+package apps
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// AnyRole is the role of the tier entry used for the containers without a specific entry
+const AnyRole = "*"
+
+// Sizing map the sizing tiers to the resources of each container role.
+// The role of a container is its name, or the value of its ROLE environment variable (see SetRole), or AnyRole.
+type Sizing struct {
+	Tiers map[types.SizingTier]map[string]v1.ResourceRequirements
+	// LimitRatios derive the limits missing from a tier from its requests, e.g. 2 for cpu set the limit to twice the request
+	LimitRatios map[v1.ResourceName]float64
+}
+
+// NewDefaultSizing returns the small, medium and large tiers for any role.
+// The memory limit is equal to the request and the cpu limit is twice the request
+func NewDefaultSizing() *Sizing {
+	requests := func(cpu, memory string) v1.ResourceRequirements {
+		return v1.ResourceRequirements{Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	return &Sizing{
+		Tiers: map[types.SizingTier]map[string]v1.ResourceRequirements{
+			types.SizingTierSmall:  {AnyRole: requests("100m", "128Mi")},
+			types.SizingTierMedium: {AnyRole: requests("500m", "512Mi")},
+			types.SizingTierLarge:  {AnyRole: requests("2", "2Gi")},
+		},
+		LimitRatios: map[v1.ResourceName]float64{
+			v1.ResourceCPU:    2,
+			v1.ResourceMemory: 1,
+		},
+	}
+}
+
+// SetTier Set the resources of the role in the tier, a custom tier is created if it does not exist
+func (s *Sizing) SetTier(tier types.SizingTier, role string, requirements v1.ResourceRequirements) *Sizing {
+	if s.Tiers == nil {
+		s.Tiers = map[types.SizingTier]map[string]v1.ResourceRequirements{}
+	}
+	if s.Tiers[tier] == nil {
+		s.Tiers[tier] = map[string]v1.ResourceRequirements{}
+	}
+	s.Tiers[tier][role] = *requirements.DeepCopy()
+	return s
+}
+
+// Requirements returns the resources of the container in the tier, with the limits derived from the requests.
+// The second value is false if the tier has no entry for the container nor for AnyRole
+func (s *Sizing) Requirements(tier types.SizingTier, container *v1.Container) (v1.ResourceRequirements, bool, error) {
+	roles, ok := s.Tiers[tier]
+	if !ok {
+		return v1.ResourceRequirements{}, false, fmt.Errorf("unknown sizing tier %s", tier)
+	}
+	requirements, ok := roles[container.Name]
+	if !ok {
+		for _, env := range container.Env {
+			if env.Name == "ROLE" {
+				requirements, ok = roles[env.Value]
+				break
+			}
+		}
+	}
+	if !ok {
+		requirements, ok = roles[AnyRole]
+	}
+	if !ok {
+		return v1.ResourceRequirements{}, false, nil
+	}
+	return DeriveLimits(requirements, s.LimitRatios), true, nil
+}
+
+// DeriveLimits returns the requirements with the missing limits computed from the requests and the ratios
+func DeriveLimits(requirements v1.ResourceRequirements, ratios map[v1.ResourceName]float64) v1.ResourceRequirements {
+	rst := requirements.DeepCopy()
+	for name, ratio := range ratios {
+		request, ok := rst.Requests[name]
+		if !ok || ratio <= 0 {
+			continue
+		}
+		if _, ok := rst.Limits[name]; ok {
+			continue
+		}
+		if rst.Limits == nil {
+			rst.Limits = v1.ResourceList{}
+		}
+		rst.Limits[name] = *resource.NewMilliQuantity(int64(math.Ceil(float64(request.MilliValue())*ratio)), request.Format)
+	}
+	return *rst
+}
+
+// ApplySizing Set the resources of all the containers from the tier, then merge the overrides of the sizing.
+// The containers without entry in the tier only receive the overrides. Nothing is done if the sizing is empty
+func (builder *PodBuilder) ApplySizing(sizing *Sizing, config types.ResourceSizing) error {
+	for _, containers := range [][]v1.Container{builder.Spec.InitContainers, builder.Spec.Containers} {
+		for idx := range containers {
+			if err := sizeContainer(&containers[idx], sizing, config); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetResourceSizing Set the resources of the container from the tier of the sizing, then merge its overrides
+func (builder *PodBuilder) SetResourceSizing(containerName string, tiers types.SizingTiers, config types.ResourceSizing) error {
+	container := builder.GetContainer(containerName)
+	if container == nil {
+		return fmt.Errorf("container %s not found", containerName)
+	}
+	return sizeContainer(container, tiers, config)
+}
+
+func sizeContainer(container *v1.Container, tiers types.SizingTiers, config types.ResourceSizing) error {
+	if config.Size != "" {
+		requirements, ok, err := tiers.Requirements(config.Size, container)
+		if err != nil {
+			return err
+		}
+		if ok {
+			container.Resources = requirements
+		}
+	}
+	if config.Resources != nil {
+		ToContainerBuilder(container).MergeResourceRequirements(*config.Resources)
+	}
+	return nil
+}
+
+// MergeResourceRequirements merge the resource requirements into the ones of the container, only the resources set are replaced
+func (builder *PodBuilder) MergeResourceRequirements(containerName string, resourceRequirements v1.ResourceRequirements) {
+	if container := builder.GetContainer(containerName); container != nil {
+		ToContainerBuilder(container).MergeResourceRequirements(resourceRequirements)
+	}
+}
+
+// MergeResourceRequirements merge the resource requirements into the ones of the container, only the resources set are replaced
+func (c *ContainerBuilder) MergeResourceRequirements(resources v1.ResourceRequirements) *ContainerBuilder {
+	c.Resources = *utils.MergeResourceRequirements(c.Resources, resources)
+	return c
+}
@@ -0,0 +1,148 @@
+package apps
+
+import (
+	"github.com/davidboxer/formation/builder"
+	"github.com/davidboxer/formation/resources/apps"
+	"github.com/davidboxer/formation/types"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type StatefulSetBuilder struct {
+	*PodBuilder
+	Companions
+	StatefulSet *appsv1.StatefulSet
+}
+
+func NewStatefulSetBuilder(name string) *StatefulSetBuilder {
+	obj := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+			Name:        name,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			ServiceName: name,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: make(map[string]string),
+			},
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{},
+			},
+		},
+	}
+	return &StatefulSetBuilder{
+		PodBuilder: &PodBuilder{
+			ConvergedGroup: &types.ConvergedGroup{},
+			Builder: builder.Builder{
+				Object: obj,
+				Name:   name,
+			},
+			Spec: &obj.Spec.Template.Spec,
+		},
+		StatefulSet: obj,
+	}
+}
+
+func (d *StatefulSetBuilder) DeepCopy() *StatefulSetBuilder {
+	statefulSetCopy := d.StatefulSet.DeepCopy()
+	cg := &types.ConvergedGroup{}
+	cg.SetConvergedGroupID(d.GetConvergedGroupID())
+	return &StatefulSetBuilder{
+		PodBuilder: &PodBuilder{
+			ConvergedGroup: cg,
+			Builder: builder.Builder{
+				Object: statefulSetCopy,
+				Name:   d.Name,
+			},
+			Spec:               &statefulSetCopy.Spec.Template.Spec,
+			sidecars:           append([]string(nil), d.sidecars...),
+			externalReferences: append([]string(nil), d.externalReferences...),
+		},
+		Companions:  d.Companions.deepCopy(),
+		StatefulSet: statefulSetCopy,
+	}
+}
+
+func (d *StatefulSetBuilder) AddMatchLabel(key, value string) {
+	d.AddMatchLabels(map[string]string{key: value})
+}
+
+func (d *StatefulSetBuilder) AddMatchLabels(labels map[string]string) {
+	//Check if MatchLabels nill, if so create it
+	if d.StatefulSet.Spec.Selector.MatchLabels == nil {
+		d.StatefulSet.Spec.Selector.MatchLabels = make(map[string]string)
+	}
+	//Check if Template Labels nill, if so create it
+	if d.StatefulSet.Spec.Template.Labels == nil {
+		d.StatefulSet.Spec.Template.Labels = make(map[string]string)
+	}
+	for k, v := range labels {
+		d.StatefulSet.Spec.Selector.MatchLabels[k] = v
+		d.StatefulSet.Spec.Template.Labels[k] = v
+		d.Labels().Add(k, v)
+	}
+}
+
+func (d *StatefulSetBuilder) SetReplicas(replicas int32) {
+	d.StatefulSet.Spec.Replicas = &replicas
+}
+
+// SetServiceName Set the name of the headless service governing the StatefulSet, default to the StatefulSet name
+func (d *StatefulSetBuilder) SetServiceName(serviceName string) *StatefulSetBuilder {
+	d.StatefulSet.Spec.ServiceName = serviceName
+	return d
+}
+
+// AddTemplateVolumeToContainer Add a VolumeClaimTemplate and mount it in the container.
+// The name of the template is the name of the volume mount, a template with the same name is only added once
+func (d *StatefulSetBuilder) AddTemplateVolumeToContainer(containerName string, containerVolume v1.VolumeMount, template v1.PersistentVolumeClaimSpec) {
+	container := d.GetContainer(containerName)
+	if container == nil {
+		return
+	}
+	found := false
+	for _, item := range d.StatefulSet.Spec.VolumeClaimTemplates {
+		if item.Name == containerVolume.Name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		d.StatefulSet.Spec.VolumeClaimTemplates = append(d.StatefulSet.Spec.VolumeClaimTemplates, v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: containerVolume.Name},
+			Spec:       *template.DeepCopy(),
+		})
+	}
+	for _, item := range container.VolumeMounts {
+		if item.Name == containerVolume.Name {
+			return
+		}
+	}
+	container.VolumeMounts = append(container.VolumeMounts, containerVolume)
+}
+
+// PodLabels returns the labels of the pod template
+func (d *StatefulSetBuilder) PodLabels() map[string]string {
+	return d.StatefulSet.Spec.Template.Labels
+}
+
+// ToResource Create the interface to the Formation controller
+func (builder *StatefulSetBuilder) ToResource() types.Resource {
+	builder.StatefulSet.Labels = builder.Labels()
+	builder.StatefulSet.Annotations = builder.Annotations()
+	builder.StatefulSet.Name = builder.Name
+	a := apps.NewStatefulSet(builder.StatefulSet)
+	a.IgnoreReplicas = builder.HasHorizontalPodAutoscaler()
+	a.External = builder.ExternalReferences()
+	a.SetConvergedGroupID(builder.GetConvergedGroupID())
+	return a
+}
+
+// ToResources Create the StatefulSet and its companions (PodDisruptionBudget, HorizontalPodAutoscaler)
+func (builder *StatefulSetBuilder) ToResources() []types.Resource {
+	resources := []types.Resource{builder.ToResource()}
+	return append(resources, builder.Companions.toResources(builder.Name, "StatefulSet", builder.StatefulSet.Labels,
+		builder.StatefulSet.Spec.Selector, builder.GetConvergedGroupID())...)
+}
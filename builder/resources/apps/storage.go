@@ -0,0 +1,79 @@
+package apps
+
+import (
+	"fmt"
+
+	"github.com/davidboxer/formation/builder/resources/core"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+)
+
+// LinkStorage Create the claims of the StorageConfig of type create and mount all the StorageConfig in the objects with LinkVolumes.
+// The templates are only mounted in the objects accepting VolumeClaimTemplates (e.g. StatefulSet), they are ignored for Deployments.
+// The returned resources need to be added to the formation before the workloads.
+func LinkStorage(objects []any, configs []types.StorageConfig) ([]types.Resource, error) {
+	var resources []types.Resource
+	for _, config := range configs {
+		if err := ValidateLinkVolumes([]types.LinkVolumeData{{Visibility: config.Visibility, VolumeMount: v1.VolumeMount{Name: config.Name}}}); err != nil {
+			return nil, err
+		}
+	}
+	links := make([]types.LinkVolumeData, 0, len(configs))
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("storage config name can not be empty")
+		}
+		spec := v1.PersistentVolumeClaimSpec{}
+		if config.Spec != nil {
+			spec = *config.Spec
+		} else if config.Type != types.StorageConfigTypeExisting {
+			return nil, fmt.Errorf("storage config %s of type %s require a spec", config.Name, config.Type)
+		}
+		mount := v1.VolumeMount{
+			Name:      config.Name,
+			MountPath: config.MountPath,
+			SubPath:   config.SubPath,
+			ReadOnly:  config.ReadOnly,
+		}
+		pvc, link, err := core.NewPersistentVolumeClaimLink(config.Name, config.Type, spec, mount, config.Visibility...)
+		if err != nil {
+			return nil, err
+		}
+		if config.Type == types.StorageConfigTypeExisting && config.ClaimName != "" {
+			link.VolumeSource.PersistentVolumeClaim.ClaimName = config.ClaimName
+		}
+		if pvc != nil {
+			resources = append(resources, pvc)
+		}
+		links = append(links, link)
+	}
+	LinkVolumes(objects, links)
+	markExistingClaims(objects, configs)
+	return resources, nil
+}
+
+// markExistingClaims add the claims of the StorageConfig of type existing mounted by the pods to their external references
+func markExistingClaims(objects []any, configs []types.StorageConfig) {
+	for _, obj := range objects {
+		pod, ok := obj.(interface{ GetPodBuilder() *PodBuilder })
+		if !ok {
+			continue
+		}
+		builder := pod.GetPodBuilder()
+		for _, config := range configs {
+			if config.Type != types.StorageConfigTypeExisting {
+				continue
+			}
+			claimName := config.ClaimName
+			if claimName == "" {
+				claimName = config.Name
+			}
+			for _, volume := range builder.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claimName {
+					builder.AddExternalReferences("persistentvolumeclaim/" + claimName)
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package apps
 
 import (
+	"fmt"
+	"reflect"
+
 	"github.com/davidboxer/formation/types"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // ApplyProbeConfiguration set the probe's parameters based on the configuration.
 // If the probe is nil then the configuration is not applied.
+// Deprecated: the probe can not be removed nor created, use ProbeFromConfiguration
 func ApplyProbeConfiguration(probe *v1.Probe, config types.ProbeConfiguration) {
 	// If the probe is nil, don't configure
 	if probe == nil {
@@ -34,3 +39,101 @@ func ApplyProbeConfiguration(probe *v1.Probe, config types.ProbeConfiguration) {
 		probe.FailureThreshold = *config.FailureThreshold
 	}
 }
+
+// ProbeFromConfiguration returns the probe configured by the configuration, the probe given is not modified.
+// The zero value keeps the probe, a disabled configuration returns nil to remove the probe. If the configuration has a handler, the probe is created
+// if it is nil and its handler is replaced, otherwise only the timing of the probe is configured.
+func ProbeFromConfiguration(probe *v1.Probe, config types.ProbeConfiguration) (*v1.Probe, error) {
+	// The zero value is a configuration that was not set, e.g. by a Go caller, the probe is kept
+	if reflect.DeepEqual(config, types.ProbeConfiguration{}) {
+		return probe, nil
+	}
+	if !config.Enable {
+		return nil, nil
+	}
+	if config.Handler != "" {
+		handler, err := probeHandler(config)
+		if err != nil {
+			return probe, err
+		}
+		if probe == nil {
+			probe = &v1.Probe{}
+		} else {
+			probe = probe.DeepCopy()
+		}
+		probe.ProbeHandler = handler
+	} else if probe == nil {
+		return nil, nil
+	} else {
+		probe = probe.DeepCopy()
+	}
+	ApplyProbeConfiguration(probe, config)
+	return probe, nil
+}
+
+func probeHandler(config types.ProbeConfiguration) (v1.ProbeHandler, error) {
+	switch config.Handler {
+	case types.ProbeHandlerHTTPGet:
+		if config.Port == nil {
+			return v1.ProbeHandler{}, fmt.Errorf("the httpGet probe require a port")
+		}
+		return NewHTTPGetProbe(config.Path, *config.Port, config.Scheme, config.Headers...).ProbeHandler, nil
+	case types.ProbeHandlerTCPSocket:
+		if config.Port == nil {
+			return v1.ProbeHandler{}, fmt.Errorf("the tcpSocket probe require a port")
+		}
+		return NewTCPSocketProbe(*config.Port).ProbeHandler, nil
+	case types.ProbeHandlerGRPC:
+		if config.Port == nil || config.Port.Type != intstr.Int {
+			return v1.ProbeHandler{}, fmt.Errorf("the grpc probe require a port number")
+		}
+		service := ""
+		if config.Service != nil {
+			service = *config.Service
+		}
+		return NewGRPCProbe(config.Port.IntVal, service).ProbeHandler, nil
+	case types.ProbeHandlerExec:
+		if len(config.Command) == 0 {
+			return v1.ProbeHandler{}, fmt.Errorf("the exec probe require a command")
+		}
+		return NewExecProbe(config.Command...).ProbeHandler, nil
+	}
+	return v1.ProbeHandler{}, fmt.Errorf("unknown probe handler %s", config.Handler)
+}
+
+// NewHTTPGetProbe Create a probe doing an HTTP GET on the path, the port can be a number or the name of a container port
+func NewHTTPGetProbe(path string, port intstr.IntOrString, scheme v1.URIScheme, headers ...v1.HTTPHeader) *v1.Probe {
+	if scheme == "" {
+		scheme = v1.URISchemeHTTP
+	}
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			HTTPGet: &v1.HTTPGetAction{Path: path, Port: port, Scheme: scheme, HTTPHeaders: headers},
+		},
+	}
+}
+
+// NewTCPSocketProbe Create a probe opening a TCP connection, the port can be a number or the name of a container port
+func NewTCPSocketProbe(port intstr.IntOrString) *v1.Probe {
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{TCPSocket: &v1.TCPSocketAction{Port: port}},
+	}
+}
+
+// NewGRPCProbe Create a probe calling the gRPC health check of the service, an empty service check the whole server
+func NewGRPCProbe(port int32, service string) *v1.Probe {
+	action := &v1.GRPCAction{Port: port}
+	if service != "" {
+		action.Service = &service
+	}
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{GRPC: action},
+	}
+}
+
+// NewExecProbe Create a probe executing the command in the container
+func NewExecProbe(command ...string) *v1.Probe {
+	return &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{Exec: &v1.ExecAction{Command: command}},
+	}
+}
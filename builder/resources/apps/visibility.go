@@ -0,0 +1,169 @@
+package apps
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/davidboxer/formation/types"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Visibility is a parsed entry of LinkVolumeData.Visibility, see the field documentation for the format
+type Visibility struct {
+	// Exclude the matching containers instead of including them
+	Exclude bool
+
+	podMatch       func(name string) bool
+	podSelector    labels.Selector
+	containerMatch func(name string) bool
+	containerTypes map[types.ContainerType]bool
+}
+
+// ParseVisibility parse an entry of LinkVolumeData.Visibility
+func ParseVisibility(value string) (*Visibility, error) {
+	v := &Visibility{}
+	rest := strings.TrimSpace(value)
+	if strings.HasPrefix(rest, "!") {
+		v.Exclude = true
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return nil, fmt.Errorf("invalid visibility %q: empty pod", value)
+	}
+
+	// Container type suffix
+	v.containerTypes = map[types.ContainerType]bool{types.ContainerTypeRegular: true, types.ContainerTypeSidecar: true}
+	if idx := strings.LastIndex(rest, "@"); idx != -1 {
+		containerTypes, err := parseContainerTypes(rest[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid visibility %q: %w", value, err)
+		}
+		v.containerTypes = containerTypes
+		rest = rest[:idx]
+	}
+
+	// Pod
+	var pod, container string
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("invalid visibility %q: unterminated label selector", value)
+		}
+		selector, err := labels.Parse(rest[1:end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid visibility %q: %w", value, err)
+		}
+		v.podSelector = selector
+		rest = rest[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, "/") {
+			return nil, fmt.Errorf("invalid visibility %q: unexpected %q after the label selector", value, rest)
+		}
+		container = strings.TrimPrefix(rest, "/")
+	} else {
+		pod, container, _ = strings.Cut(rest, "/")
+		match, err := nameMatcher(pod, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid visibility %q: %w", value, err)
+		}
+		v.podMatch = match
+	}
+
+	// Container
+	if container == "" {
+		container = "*"
+	}
+	match, err := nameMatcher(container, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid visibility %q: %w", value, err)
+	}
+	v.containerMatch = match
+	return v, nil
+}
+
+// Match returns true if the container of the pod match the visibility, the Exclude flag is not taken into account
+func (v *Visibility) Match(podName string, podLabels map[string]string, containerName string, containerType types.ContainerType) bool {
+	if !v.containerTypes[containerType] {
+		return false
+	}
+	return v.MatchPod(podName, podLabels) && v.containerMatch(containerName)
+}
+
+// MatchPod returns true if the pod match the visibility, the container part is not taken into account
+func (v *Visibility) MatchPod(podName string, podLabels map[string]string) bool {
+	if v.podSelector != nil {
+		return v.podSelector.Matches(labels.Set(podLabels))
+	}
+	return v.podMatch(podName)
+}
+
+// ValidateLinkVolumes returns an error listing all the unparsable visibility entries
+func ValidateLinkVolumes(volumes []types.LinkVolumeData) error {
+	var errs []string
+	for _, volume := range volumes {
+		for _, visibility := range volume.Visibility {
+			if _, err := ParseVisibility(visibility); err != nil {
+				errs = append(errs, fmt.Sprintf("volume %s: %s", volume.VolumeMount.Name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func parseContainerTypes(value string) (map[types.ContainerType]bool, error) {
+	containerTypes := map[types.ContainerType]bool{}
+	for _, item := range strings.Split(value, "+") {
+		switch containerType := types.ContainerType(item); containerType {
+		case types.ContainerTypeRegular, types.ContainerTypeInit, types.ContainerTypeSidecar:
+			containerTypes[containerType] = true
+		case "all":
+			containerTypes[types.ContainerTypeRegular] = true
+			containerTypes[types.ContainerTypeInit] = true
+			containerTypes[types.ContainerTypeSidecar] = true
+		default:
+			return nil, fmt.Errorf("unknown container type %q", item)
+		}
+	}
+	return containerTypes, nil
+}
+
+// nameMatcher returns the matcher of a name, a glob or a regex prefixed with ~.
+// The pod names also match with an instance prefix
+func nameMatcher(pattern string, pod bool) (func(string) bool, error) {
+	switch {
+	case pattern == "*":
+		return func(string) bool { return true }, nil
+	case strings.HasPrefix(pattern, "~"):
+		re, err := regexp.Compile("^(?:" + pattern[1:] + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		return func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		}, nil
+	}
+	var errs []string
+	if pod {
+		errs = validation.IsDNS1123Subdomain(pattern)
+	} else {
+		errs = validation.IsDNS1123Label(pattern)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid name %q: %s", pattern, strings.Join(errs, ", "))
+	}
+	if pod {
+		return func(name string) bool { return podNameMatchVisibility(name, pattern) }, nil
+	}
+	return func(name string) bool { return name == pattern }, nil
+}
@@ -4,8 +4,13 @@ import (
 	"strings"
 
 	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
 )
 
+// LinkVolumes mount the volumes in the containers matching their visibility.
+// A container is selected when it matches at least one entry and no exclusion, invalid entries are logged and ignored.
+// Use ValidateLinkVolumes to report them as errors
 func LinkVolumes(objects []any, volumes []types.LinkVolumeData) {
 	// Reduce the list of object to only types.ResourcesName
 	var objs []any
@@ -18,40 +23,40 @@ func LinkVolumes(objects []any, volumes []types.LinkVolumeData) {
 
 	//Loop over all the volumes
 	for _, volume := range volumes {
+		var visibilities []*Visibility
+		for _, value := range volume.Visibility {
+			visibility, err := ParseVisibility(value)
+			if err != nil {
+				log.Error().Err(err).Str("volume", volume.VolumeMount.Name).Msg("visibility ignored")
+				continue
+			}
+			visibilities = append(visibilities, visibility)
+		}
 		for _, obj := range objs {
-			// get the list of resourcesName
-			resourcesName := obj.(types.ResourcesName)
-			// Check if any of the resourcesName match the visibility
-			for _, visibility := range volume.Visibility {
-				// split the visibility into podName and containerName
-				podName, containerName := splitVisibility(visibility)
-				// Look over resourcesName to see if any of them match the visibility
-				for _, name := range resourcesName.ResourcesName() {
-					// split the name into podName and containerName
-					resPodName, resContainerName := splitVisibility(name)
-					// Check if the podName and containerName match the visibility
-					if podNameMatchVisibility(resPodName, podName) && containerNameMatchVisibility(resContainerName, containerName) {
-						// Check if the Volume is an EnvFromSource
-						if volume.EnvFromSource != nil {
-							// check if object have type.AddEnvFromSource
-							if addEnvFromSource, ok := obj.(types.AddEnvFromSourceToContainer); ok {
-								// Add the envFromSource
-								addEnvFromSource.AddEnvFromSourceToContainer(resContainerName, *volume.EnvFromSource)
-							}
+			podName, podLabels, containers := visibilityCandidates(obj)
+			for _, containerName := range utils.SortedKeys(containers) {
+				if !visibilityMatch(visibilities, podName, podLabels, containerName, containers[containerName]) {
+					continue
+				}
+				// Check if the Volume is an EnvFromSource
+				if volume.EnvFromSource != nil {
+					// check if object have type.AddEnvFromSource
+					if addEnvFromSource, ok := obj.(types.AddEnvFromSourceToContainer); ok {
+						// Add the envFromSource
+						addEnvFromSource.AddEnvFromSourceToContainer(containerName, *volume.EnvFromSource)
+					}
 
-						} else if volume.Template != nil { // Check if the Volume is an Template
-							// check if object have type.AddTemplateVolume
-							if addTemplateVolume, ok := obj.(types.AddTemplateVolumeToContainer); ok {
-								// Add the template volume
-								addTemplateVolume.AddVolumeToContainer(resContainerName, volume.VolumeMount, *volume.Template)
-							}
-						} else if volume.VolumeSource != nil { // Check if the Volume is an VolumeSource
-							// check if object have type.AddVolume
-							if addVolume, ok := obj.(types.AddVolumeToContainer); ok {
-								// Add the volume
-								addVolume.AddVolumeToContainer(resContainerName, volume.VolumeMount, *volume.VolumeSource)
-							}
-						}
+				} else if volume.Template != nil { // Check if the Volume is an Template
+					// check if object have type.AddTemplateVolume
+					if addTemplateVolume, ok := obj.(types.AddTemplateVolumeToContainer); ok {
+						// Add the template volume
+						addTemplateVolume.AddTemplateVolumeToContainer(containerName, volume.VolumeMount, *volume.Template)
+					}
+				} else if volume.VolumeSource != nil { // Check if the Volume is an VolumeSource
+					// check if object have type.AddVolume
+					if addVolume, ok := obj.(types.AddVolumeToContainer); ok {
+						// Add the volume
+						addVolume.AddVolumeToContainer(containerName, volume.VolumeMount, *volume.VolumeSource)
 					}
 				}
 			}
@@ -59,6 +64,44 @@ func LinkVolumes(objects []any, volumes []types.LinkVolumeData) {
 	}
 }
 
+// visibilityCandidates returns the pod name, the pod labels and the type of the containers of the object.
+// Without types.ContainerTypes, the containers of ResourcesName are considered regular
+func visibilityCandidates(obj any) (string, map[string]string, map[string]types.ContainerType) {
+	var podName string
+	containers := map[string]types.ContainerType{}
+	for _, name := range obj.(types.ResourcesName).ResourcesName() {
+		resPodName, resContainerName := splitVisibility(name)
+		podName = resPodName
+		containers[resContainerName] = types.ContainerTypeRegular
+	}
+	if named, ok := obj.(interface{ GetName() string }); ok {
+		podName = named.GetName()
+	}
+	if containerTypes, ok := obj.(types.ContainerTypes); ok {
+		containers = containerTypes.ContainerTypes()
+	}
+	var podLabels map[string]string
+	if labeled, ok := obj.(types.PodLabels); ok {
+		podLabels = labeled.PodLabels()
+	}
+	return podName, podLabels, containers
+}
+
+func visibilityMatch(visibilities []*Visibility, podName string, podLabels map[string]string,
+	containerName string, containerType types.ContainerType) bool {
+	included := false
+	for _, visibility := range visibilities {
+		if !visibility.Match(podName, podLabels, containerName, containerType) {
+			continue
+		}
+		if visibility.Exclude {
+			return false
+		}
+		included = true
+	}
+	return included
+}
+
 func splitVisibility(visibility string) (string, string) {
 	podName := visibility
 	containerName := ""
@@ -83,16 +126,6 @@ func podNameMatchVisibility(podName string, visibility string) bool {
 	}
 	return false
 }
-func containerNameMatchVisibility(containerName string, visibility string) bool {
-	if visibility == "*" {
-		return true
-	}
-	if visibility == containerName {
-		return true
-	}
-	return false
-}
-
 func FindAllPodBuilderWithContainerName(builders []*PodBuilder, containerName string) []*PodBuilder {
 	retList := []*PodBuilder{}
 	for index, builder := range builders {
@@ -65,12 +65,18 @@ func (d *JobBuilder) AddMatchLabels(labels map[string]string) {
 	}
 }
 
+// PodLabels returns the labels of the pod template
+func (d *JobBuilder) PodLabels() map[string]string {
+	return d.Job.Spec.Template.Labels
+}
+
 // ToResource Create the interface to the Formation controller
 func (builder *JobBuilder) ToResource() types.Resource {
 	builder.Job.Labels = builder.Labels()
 	builder.Job.Annotations = builder.Annotations()
 	builder.Job.Name = builder.Name
 	a := batch.NewJob(builder.Job)
+	a.External = builder.ExternalReferences()
 	a.SetConvergedGroupID(builder.GetConvergedGroupID())
 	return a
 }
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/davidboxer/formation/builder"
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+type ConfigMapBuilder struct {
+	*types.ConvergedGroup
+	builder.Builder
+	ConfigMap *v1.ConfigMap
+}
+
+func NewConfigMapBuilder(name string) *ConfigMapBuilder {
+	obj := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+			Name:        name,
+		},
+		Data:       make(map[string]string),
+		BinaryData: make(map[string][]byte),
+	}
+	return &ConfigMapBuilder{
+		ConvergedGroup: &types.ConvergedGroup{},
+		Builder: builder.Builder{
+			Object: obj,
+			Name:   name,
+		},
+		ConfigMap: obj,
+	}
+}
+
+// AddData Add a string value to the ConfigMap
+func (b *ConfigMapBuilder) AddData(key, value string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	delete(b.ConfigMap.BinaryData, key)
+	b.ConfigMap.Data[key] = value
+	return nil
+}
+
+// AddBinaryData Add a binary value to the ConfigMap
+func (b *ConfigMapBuilder) AddBinaryData(key string, value []byte) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	delete(b.ConfigMap.Data, key)
+	b.ConfigMap.BinaryData[key] = value
+	return nil
+}
+
+// AddFile Add the content of a file to the ConfigMap. If the key is empty, the file name is used.
+// Content that is not valid UTF-8 is stored in BinaryData
+func (b *ConfigMapBuilder) AddFile(key, filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		key = filepath.Base(filePath)
+	}
+	return b.addContent(key, content)
+}
+
+// AddDirectory Add all the regular files of a directory to the ConfigMap, using the file name as key.
+// Subdirectories are ignored
+func (b *ConfigMapBuilder) AddDirectory(dirPath string) error {
+	return b.AddFS(os.DirFS(dirPath), ".")
+}
+
+// AddFS Add all the regular files of a directory in a fs.FS (e.g. embed.FS) to the ConfigMap, using the file name as key.
+// Subdirectories are ignored
+func (b *ConfigMapBuilder) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := b.addContent(entry.Name(), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTemplate Render a Go text/template with the values and add the result to the ConfigMap
+func (b *ConfigMapBuilder) AddTemplate(key, text string, values any) error {
+	tmpl, err := template.New(key).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, values); err != nil {
+		return err
+	}
+	return b.AddData(key, buffer.String())
+}
+
+// AddTemplateFromFS Render a Go text/template file from a fs.FS with the values and add the result to the ConfigMap.
+// If the key is empty, the file name without the .tmpl extension is used
+func (b *ConfigMapBuilder) AddTemplateFromFS(fsys fs.FS, key, filePath string, values any) error {
+	content, err := fs.ReadFile(fsys, filePath)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		key = strings.TrimSuffix(path.Base(filePath), ".tmpl")
+	}
+	return b.AddTemplate(key, string(content), values)
+}
+
+// Size returns the size in bytes of the data of the ConfigMap
+func (b *ConfigMapBuilder) Size() int {
+	return core.ConfigMapDataSize(b.ConfigMap)
+}
+
+// Validate returns an error if the data of the ConfigMap is larger than the 1 MiB limit
+func (b *ConfigMapBuilder) Validate() error {
+	if size := b.Size(); size > core.MaxConfigMapSize {
+		return fmt.Errorf("configmap %s is too large: %d bytes, maximum is %d bytes", b.Name, size, core.MaxConfigMapSize)
+	}
+	return nil
+}
+
+// ToResource Create the interface to the Formation controller
+func (b *ConfigMapBuilder) ToResource() types.Resource {
+	b.ConfigMap.Labels = b.Labels()
+	b.ConfigMap.Annotations = b.Annotations()
+	b.ConfigMap.Name = b.Name
+	a := core.NewConfigMap(b.ConfigMap)
+	a.SetConvergedGroupID(b.GetConvergedGroupID())
+	return a
+}
+
+func (b *ConfigMapBuilder) addContent(key string, content []byte) error {
+	if utf8.Valid(content) {
+		return b.AddData(key, string(content))
+	}
+	return b.AddBinaryData(key, content)
+}
+
+func validateKey(key string) error {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return fmt.Errorf("invalid configmap key %s: %s", key, strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ResolveExternalSecrets create a managed Secret for each LinkVolumeData referencing an external secret.
+// The returned LinkVolumeData point to the managed Secret and can be applied with LinkVolumes,
+// the returned resources need to be added to the formation before the workloads using them.
+// The sources are looked up by name in sources, the references without source use the "kubernetes" source.
+// No source is available by default, e.g. a KubernetesSecretSource with its allowed namespaces must be registered explicitly.
+func ResolveExternalSecrets(volumes []types.LinkVolumeData, sources map[string]core.SecretSource) ([]types.LinkVolumeData, []types.Resource, error) {
+	resolved := make([]types.LinkVolumeData, 0, len(volumes))
+	var resources []types.Resource
+	created := map[string]types.ExternalSecretReference{}
+	for _, volume := range volumes {
+		if volume.ExternalSecret == nil {
+			resolved = append(resolved, volume)
+			continue
+		}
+		ref := *volume.ExternalSecret
+		sourceName := ref.Source
+		if sourceName == "" {
+			sourceName = core.KubernetesSecretSourceName
+		}
+		source, ok := sources[sourceName]
+		if !ok {
+			return nil, nil, fmt.Errorf("secret source %s not found for external secret %s", sourceName, ref.Name)
+		}
+		localName := ref.LocalName
+		if localName == "" {
+			prefix := ref.Namespace
+			if sourceName != core.KubernetesSecretSourceName || prefix == "" {
+				prefix = sourceName
+			}
+			localName = prefix + "-" + ref.Name
+		}
+		// The same external secret can be linked multiple times, only create it once
+		if previous, ok := created[localName]; ok {
+			if previous.Name != ref.Name || previous.Namespace != ref.Namespace || previous.Source != ref.Source {
+				return nil, nil, fmt.Errorf("external secrets %s and %s use the same local name %s", previous.Name, ref.Name, localName)
+			}
+		} else {
+			created[localName] = ref
+			resources = append(resources, core.NewExternalSecret(localName, source, ref))
+		}
+
+		volume = *volume.DeepCopy()
+		volume.ExternalSecret = nil
+		switch {
+		case volume.EnvFromSource != nil:
+			volume.EnvFromSource.ConfigMapRef = nil
+			volume.EnvFromSource.SecretRef = &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: localName}}
+		case volume.VolumeSource != nil && volume.VolumeSource.Secret != nil:
+			volume.VolumeSource.Secret.SecretName = localName
+		default:
+			volume.VolumeSource = &v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: localName}}
+		}
+		resolved = append(resolved, volume)
+	}
+	return resolved, resources, nil
+}
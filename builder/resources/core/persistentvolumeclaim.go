@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewPersistentVolumeClaimLink returns the LinkVolumeData mounting a claim, following the StorageConfigType semantics:
+//   - StorageConfigTypeCreate: the claim is created by the formation, the returned resource must be added to the formation
+//   - StorageConfigTypeExisting: the claim already exist, no resource is returned
+//   - StorageConfigTypeTemplate: the spec is used as a VolumeClaimTemplate, no resource is returned
+func NewPersistentVolumeClaimLink(name string, storageType types.StorageConfigType, spec v1.PersistentVolumeClaimSpec,
+	mount v1.VolumeMount, visibility ...string) (*core.PersistentVolumeClaim, types.LinkVolumeData, error) {
+	if mount.Name == "" {
+		mount.Name = name
+	}
+	link := types.LinkVolumeData{
+		Visibility:  visibility,
+		VolumeMount: mount,
+	}
+	claimSource := &v1.VolumeSource{
+		PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: name, ReadOnly: mount.ReadOnly},
+	}
+	switch storageType {
+	case types.StorageConfigTypeCreate:
+		link.VolumeSource = claimSource
+		pvc := core.NewPersistentVolumeClaim(&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       *spec.DeepCopy(),
+		})
+		return pvc, link, nil
+	case types.StorageConfigTypeExisting:
+		link.VolumeSource = claimSource
+		return nil, link, nil
+	case types.StorageConfigTypeTemplate, "":
+		link.Template = spec.DeepCopy()
+		return nil, link, nil
+	}
+	return nil, link, fmt.Errorf("unknown storage config type %s", storageType)
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"github.com/davidboxer/formation/builder"
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type ServiceBuilder struct {
+	*types.ConvergedGroup
+	builder.Builder
+	Service *v1.Service
+}
+
+func NewServiceBuilder(name string) *ServiceBuilder {
+	obj := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+			Name:        name,
+		},
+		Spec: v1.ServiceSpec{
+			Type:     v1.ServiceTypeClusterIP,
+			Selector: make(map[string]string),
+		},
+	}
+	return &ServiceBuilder{
+		ConvergedGroup: &types.ConvergedGroup{},
+		Builder: builder.Builder{
+			Object: obj,
+			Name:   name,
+		},
+		Service: obj,
+	}
+}
+
+// AddSelector Add the labels of the pods targeted by the service
+func (b *ServiceBuilder) AddSelector(labels map[string]string) *ServiceBuilder {
+	if b.Service.Spec.Selector == nil {
+		b.Service.Spec.Selector = make(map[string]string)
+	}
+	for k, v := range labels {
+		b.Service.Spec.Selector[k] = v
+	}
+	return b
+}
+
+// AddPort Add a port to the service, the target port is the named port of the container
+func (b *ServiceBuilder) AddPort(name string, port int32, targetPort string) *ServiceBuilder {
+	return b.AddServicePort(v1.ServicePort{
+		Name:       name,
+		Port:       port,
+		TargetPort: intstr.FromString(targetPort),
+		Protocol:   v1.ProtocolTCP,
+	})
+}
+
+// AddServicePort Add a port to the service, a port with the same name is overwritten
+func (b *ServiceBuilder) AddServicePort(port v1.ServicePort) *ServiceBuilder {
+	for idx, p := range b.Service.Spec.Ports {
+		if p.Name == port.Name {
+			b.Service.Spec.Ports[idx] = port
+			return b
+		}
+	}
+	b.Service.Spec.Ports = append(b.Service.Spec.Ports, port)
+	return b
+}
+
+// SetType Set the type of the service
+func (b *ServiceBuilder) SetType(serviceType v1.ServiceType) *ServiceBuilder {
+	b.Service.Spec.Type = serviceType
+	return b
+}
+
+// SetHeadless Set the service as headless (ClusterIP None), typically used by StatefulSet
+func (b *ServiceBuilder) SetHeadless() *ServiceBuilder {
+	b.Service.Spec.Type = v1.ServiceTypeClusterIP
+	b.Service.Spec.ClusterIP = v1.ClusterIPNone
+	return b
+}
+
+// TLSGenerator returns a TLSGenerator with the DNS names of the service as subject alternative names.
+// For a headless service, the wildcard of the pods DNS names is added as well
+func (b *ServiceBuilder) TLSGenerator() *core.TLSGenerator {
+	generator := &core.TLSGenerator{
+		CommonName:   b.Name,
+		ServiceNames: []string{b.Name},
+	}
+	if b.Service.Spec.ClusterIP == v1.ClusterIPNone {
+		generator.ServiceNames = append(generator.ServiceNames, "*."+b.Name)
+	}
+	return generator
+}
+
+// ToResource Create the interface to the Formation controller
+func (b *ServiceBuilder) ToResource() types.Resource {
+	b.Service.Labels = b.Labels()
+	b.Service.Annotations = b.Annotations()
+	b.Service.Name = b.Name
+	a := core.NewService(b.Service)
+	a.SetConvergedGroupID(b.GetConvergedGroupID())
+	return a
+}
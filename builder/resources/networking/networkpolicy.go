@@ -0,0 +1,105 @@
+package networking
+
+import (
+	"github.com/davidboxer/formation/builder"
+	"github.com/davidboxer/formation/resources/networking"
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type NetworkPolicyBuilder struct {
+	*types.ConvergedGroup
+	builder.Builder
+	NetworkPolicy *networkingv1.NetworkPolicy
+}
+
+// NewNetworkPolicyBuilder create a NetworkPolicy selecting all the pods of the namespace.
+// Without any rules, the policy deny all the ingress traffic to the selected pods
+func NewNetworkPolicyBuilder(name string) *NetworkPolicyBuilder {
+	obj := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      make(map[string]string),
+			Annotations: make(map[string]string),
+			Name:        name,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+	return &NetworkPolicyBuilder{
+		ConvergedGroup: &types.ConvergedGroup{},
+		Builder: builder.Builder{
+			Object: obj,
+			Name:   name,
+		},
+		NetworkPolicy: obj,
+	}
+}
+
+// SetPodSelector Set the labels of the pods the policy applies to
+func (b *NetworkPolicyBuilder) SetPodSelector(labels map[string]string) *NetworkPolicyBuilder {
+	b.NetworkPolicy.Spec.PodSelector = metav1.LabelSelector{MatchLabels: utils.MergeMap(nil, labels)}
+	return b
+}
+
+// AddPolicyType Add a policy type to the policy, the egress traffic is only restricted once Egress is added
+func (b *NetworkPolicyBuilder) AddPolicyType(policyType networkingv1.PolicyType) *NetworkPolicyBuilder {
+	for _, t := range b.NetworkPolicy.Spec.PolicyTypes {
+		if t == policyType {
+			return b
+		}
+	}
+	b.NetworkPolicy.Spec.PolicyTypes = append(b.NetworkPolicy.Spec.PolicyTypes, policyType)
+	return b
+}
+
+// AddIngressFromPods Allow the traffic from the pods matching the labels on the given ports.
+// If no ports are given, all the ports are allowed
+func (b *NetworkPolicyBuilder) AddIngressFromPods(labels map[string]string, ports ...networkingv1.NetworkPolicyPort) *NetworkPolicyBuilder {
+	b.NetworkPolicy.Spec.Ingress = append(b.NetworkPolicy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+		From: []networkingv1.NetworkPolicyPeer{
+			{PodSelector: &metav1.LabelSelector{MatchLabels: utils.MergeMap(nil, labels)}},
+		},
+		Ports: ports,
+	})
+	return b
+}
+
+// AddEgressToPods Allow the traffic to the pods matching the labels on the given ports.
+// If no ports are given, all the ports are allowed
+func (b *NetworkPolicyBuilder) AddEgressToPods(labels map[string]string, ports ...networkingv1.NetworkPolicyPort) *NetworkPolicyBuilder {
+	b.AddPolicyType(networkingv1.PolicyTypeEgress)
+	b.NetworkPolicy.Spec.Egress = append(b.NetworkPolicy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{
+			{PodSelector: &metav1.LabelSelector{MatchLabels: utils.MergeMap(nil, labels)}},
+		},
+		Ports: ports,
+	})
+	return b
+}
+
+// AddIngressRule Add a raw ingress rule to the policy
+func (b *NetworkPolicyBuilder) AddIngressRule(rule networkingv1.NetworkPolicyIngressRule) *NetworkPolicyBuilder {
+	b.NetworkPolicy.Spec.Ingress = append(b.NetworkPolicy.Spec.Ingress, rule)
+	return b
+}
+
+// AddEgressRule Add a raw egress rule to the policy
+func (b *NetworkPolicyBuilder) AddEgressRule(rule networkingv1.NetworkPolicyEgressRule) *NetworkPolicyBuilder {
+	b.AddPolicyType(networkingv1.PolicyTypeEgress)
+	b.NetworkPolicy.Spec.Egress = append(b.NetworkPolicy.Spec.Egress, rule)
+	return b
+}
+
+// ToResource Create the interface to the Formation controller
+func (b *NetworkPolicyBuilder) ToResource() types.Resource {
+	b.NetworkPolicy.Labels = b.Labels()
+	b.NetworkPolicy.Annotations = b.Annotations()
+	b.NetworkPolicy.Name = b.Name
+	a := networking.NewNetworkPolicy(b.NetworkPolicy)
+	a.SetConvergedGroupID(b.GetConvergedGroupID())
+	return a
+}
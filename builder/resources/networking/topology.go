@@ -0,0 +1,184 @@
+package networking
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DefaultDenyName is the name of the NetworkPolicy denying the traffic in the namespace, prefixed with the formation
+const DefaultDenyName = "default-deny"
+
+// Edge is a declared connection from the pods of a builder to a service
+type Edge struct {
+	// From is the name of the pod builder opening the connection
+	From string `json:"from" yaml:"from"`
+	// To is the name of the service receiving the connection
+	To string `json:"to" yaml:"to"`
+	// Ports is the list of service port names allowed, if empty all the ports of the service are allowed
+	Ports []string `json:"ports,omitempty" yaml:"ports"`
+}
+
+// NamedPodLabels is a pod builder of the topology, e.g. a DeploymentBuilder or a StatefulSetBuilder
+type NamedPodLabels interface {
+	types.PodLabels
+	GetName() string
+}
+
+// TopologyOptions of the policies generated by NetworkPoliciesFromTopology
+type TopologyOptions struct {
+	// DenyEgress The default deny policy also deny the egress traffic, except the DNS queries (port 53),
+	// and each edge is also allowed by an egress policy of the source pods.
+	// The other egress traffic (e.g. to the API server or outside the cluster) need their own policies
+	DenyEgress bool
+}
+
+// NetworkPoliciesFromTopology Generate a default deny policy plus one policy per service allowing the declared edges.
+// The policies are named <formation>-default-deny, <formation>-<service>-ingress and <formation>-<pod>-egress.
+// The formation is left empty when the resources are added to a formation.Formation, it prefixes the names with its instance.
+// The source pods are matched with the labels of their pod template (see AddMatchLabels),
+// the destination pods with the selector of the service.
+// The ports are the target ports of the service, named ports are kept as named ports.
+func NetworkPoliciesFromTopology(formation string, pods []NamedPodLabels, services []*v1.Service, edges []Edge, opts TopologyOptions) ([]types.Resource, error) {
+	prefix := ""
+	if formation != "" {
+		prefix = formation + "-"
+	}
+	podMap := map[string]NamedPodLabels{}
+	for _, pod := range pods {
+		if isNil(pod) {
+			return nil, fmt.Errorf("network topology: nil pod builder")
+		}
+		podMap[pod.GetName()] = pod
+	}
+	serviceMap := map[string]*v1.Service{}
+	for idx, service := range services {
+		if service == nil {
+			return nil, fmt.Errorf("network topology: nil service")
+		}
+		serviceMap[service.Name] = services[idx]
+	}
+
+	policies := map[string]*NetworkPolicyBuilder{}
+	egressPolicies := map[string]*NetworkPolicyBuilder{}
+	for _, edge := range edges {
+		pod, ok := podMap[edge.From]
+		if !ok {
+			return nil, fmt.Errorf("network edge %s -> %s: pod %s not found", edge.From, edge.To, edge.From)
+		}
+		if len(pod.PodLabels()) == 0 {
+			return nil, fmt.Errorf("network edge %s -> %s: pod %s has no labels", edge.From, edge.To, edge.From)
+		}
+		service, ok := serviceMap[edge.To]
+		if !ok {
+			return nil, fmt.Errorf("network edge %s -> %s: service %s not found", edge.From, edge.To, edge.To)
+		}
+		if len(service.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("network edge %s -> %s: service %s has no selector", edge.From, edge.To, edge.To)
+		}
+		ports, err := servicePolicyPorts(service, edge.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("network edge %s -> %s: %w", edge.From, edge.To, err)
+		}
+		policy, ok := policies[service.Name]
+		if !ok {
+			policy = NewNetworkPolicyBuilder(prefix + service.Name + "-ingress").SetPodSelector(service.Spec.Selector)
+			policies[service.Name] = policy
+		}
+		policy.AddIngressFromPods(pod.PodLabels(), ports...)
+		if !opts.DenyEgress {
+			continue
+		}
+		egress, ok := egressPolicies[edge.From]
+		if !ok {
+			egress = NewNetworkPolicyBuilder(prefix + edge.From + "-egress").SetPodSelector(pod.PodLabels())
+			// Only the egress traffic of the source pods is allowed, their ingress traffic is left to the other policies
+			egress.NetworkPolicy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+			egressPolicies[edge.From] = egress
+		}
+		egress.AddEgressToPods(service.Spec.Selector, ports...)
+	}
+
+	resources := []types.Resource{defaultDenyPolicy(prefix, opts).ToResource()}
+	for _, policies := range []map[string]*NetworkPolicyBuilder{policies, egressPolicies} {
+		for _, name := range sortedNames(policies) {
+			resources = append(resources, policies[name].ToResource())
+		}
+	}
+	return resources, nil
+}
+
+// defaultDenyPolicy deny the ingress traffic to all the pods, and their egress traffic except DNS with DenyEgress
+func defaultDenyPolicy(prefix string, opts TopologyOptions) *NetworkPolicyBuilder {
+	policy := NewNetworkPolicyBuilder(prefix + DefaultDenyName)
+	if !opts.DenyEgress {
+		return policy
+	}
+	dns := intstr.FromInt(53)
+	udp, tcp := v1.ProtocolUDP, v1.ProtocolTCP
+	return policy.AddEgressRule(networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dns}, {Protocol: &tcp, Port: &dns}},
+	})
+}
+
+// sortedNames Map are not ordered, sort the policies to keep the formation order stable
+func sortedNames(policies map[string]*NetworkPolicyBuilder) []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isNil returns true for a nil interface and for an interface holding a nil pointer, e.g. a nil *DeploymentBuilder
+func isNil(pod NamedPodLabels) bool {
+	if pod == nil {
+		return true
+	}
+	value := reflect.ValueOf(pod)
+	return value.Kind() == reflect.Ptr && value.IsNil()
+}
+
+// servicePolicyPorts returns the pod ports targeted by the service ports matching the names
+func servicePolicyPorts(service *v1.Service, names []string) ([]networkingv1.NetworkPolicyPort, error) {
+	var ports []networkingv1.NetworkPolicyPort
+	if len(names) == 0 {
+		for _, port := range service.Spec.Ports {
+			ports = append(ports, toPolicyPort(port))
+		}
+		return ports, nil
+	}
+	for _, name := range names {
+		found := false
+		for _, port := range service.Spec.Ports {
+			if port.Name == name {
+				ports = append(ports, toPolicyPort(port))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("port %s not found in service %s", name, service.Name)
+		}
+	}
+	return ports, nil
+}
+
+func toPolicyPort(port v1.ServicePort) networkingv1.NetworkPolicyPort {
+	target := port.TargetPort
+	// When the target port is not set, the service use the same value as the port
+	if target.Type == intstr.Int && target.IntVal == 0 {
+		target = intstr.FromInt(int(port.Port))
+	}
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = v1.ProtocolTCP
+	}
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &target}
+}
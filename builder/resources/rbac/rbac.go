@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"github.com/davidboxer/formation/builder/resources/apps"
+	"github.com/davidboxer/formation/resources/core"
+	"github.com/davidboxer/formation/resources/rbac"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewServiceAccountWithRules create the ServiceAccount, Role and RoleBinding giving the rules to the pod.
+// All three resources share the same name and the pod ServiceAccountName is set to it.
+// The resources are returned in the order they need to be reconciled.
+func NewServiceAccountWithRules(name string, pod *apps.PodBuilder, rules []rbacv1.PolicyRule) []types.Resource {
+	serviceAccount := newServiceAccount(name)
+	role := rbac.NewRole(&rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	})
+	roleBinding := rbac.NewRoleBinding(&rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: name},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+	})
+	if pod != nil {
+		pod.SetServiceAccount(name)
+	}
+	return []types.Resource{serviceAccount, role, roleBinding}
+}
+
+// NewServiceAccountWithClusterRules create the ServiceAccount, ClusterRole and ClusterRoleBinding giving the rules to the pod.
+// Since the ClusterRoleBinding is not namespaced, the namespace of the ServiceAccount must be provided.
+// The cluster resources are named <namespace>-<name> to avoid collision between namespaces.
+// The resources are returned in the order they need to be reconciled.
+func NewServiceAccountWithClusterRules(name, namespace string, pod *apps.PodBuilder, rules []rbacv1.PolicyRule) []types.Resource {
+	clusterName := namespace + "-" + name
+	serviceAccount := newServiceAccount(name)
+	clusterRole := rbac.NewClusterRole(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Rules:      rules,
+	})
+	clusterRoleBinding := rbac.NewClusterRoleBinding(&rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: namespace},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterName,
+		},
+	})
+	if pod != nil {
+		pod.SetServiceAccount(name)
+	}
+	return []types.Resource{serviceAccount, clusterRole, clusterRoleBinding}
+}
+
+func newServiceAccount(name string) *core.ServiceAccount {
+	return core.NewServiceAccount(&v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	})
+}
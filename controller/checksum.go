@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// configChecksum compute the checksum of the data of all the ConfigMaps and Secrets referenced by the pod spec.
+// Missing objects are skipped, they can be optional or created later in the formation.
+func (c Controller) configChecksum(ctx context.Context, spec *v1.PodSpec, namespace string) (string, error) {
+	configMaps, secrets := utils.PodSpecReferences(spec)
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return "", nil
+	}
+	hf := sha256.New()
+	for _, name := range configMaps {
+		configMap := &v1.ConfigMap{}
+		if err := c.cli.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, configMap); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		hf.Write([]byte("configmap/" + name + "\n"))
+		for _, key := range utils.SortedKeys(configMap.Data) {
+			hf.Write([]byte(key + "=" + configMap.Data[key] + "\n"))
+		}
+		for _, key := range utils.SortedKeys(configMap.BinaryData) {
+			hf.Write([]byte(key + "="))
+			hf.Write(configMap.BinaryData[key])
+			hf.Write([]byte("\n"))
+		}
+	}
+	for _, name := range secrets {
+		secret := &v1.Secret{}
+		if err := c.cli.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		hf.Write([]byte("secret/" + name + "\n"))
+		for _, key := range utils.SortedKeys(secret.Data) {
+			hf.Write([]byte(key + "="))
+			hf.Write(secret.Data[key])
+			hf.Write([]byte("\n"))
+		}
+	}
+	return hex.EncodeToString(hf.Sum(nil)), nil
+}
+
+// stampConfigChecksum set the checksum of the referenced ConfigMaps and Secrets as a pod template annotation.
+// A change of the referenced data change the pod template and trigger a rollout of the workload.
+// The Jobs and the ReplicaSets do not roll out their pods, they are not stamped.
+func (c Controller) stampConfigChecksum(ctx context.Context, obj client.Object, namespace string) error {
+	switch obj.(type) {
+	case *batchv1.Job, *appsv1.ReplicaSet:
+		return nil
+	}
+	template := utils.PodTemplate(obj)
+	if template == nil {
+		return nil
+	}
+	checksum, err := c.configChecksum(ctx, &template.Spec, namespace)
+	if err != nil {
+		log.Error().Caller().Err(err).Msg("unable to compute the config checksum")
+		return err
+	}
+	if checksum == "" {
+		return nil
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[types.ConfigChecksumKey] = checksum
+	return nil
+}
@@ -31,6 +31,14 @@ type Controller struct {
 	object client.Object
 
 	transformers *Transformers
+	// versionedNames map the type/name of the Versioned resources of the formation to their versioned name
+	versionedNames map[string]string
+	// patches of the owner, applied on the objects of the formation
+	patches []types.Patch
+
+	configChecksumEnabled bool
+	validationDisabled    bool
+	policies              []types.Policy
 }
 
 var rejectedPatchList = []string{
@@ -53,12 +61,39 @@ func (c *Controller) AddTransformer(transformer ...mergo.Transformers) {
 	c.transformers.Add(transformer...)
 }
 
+// EnableConfigChecksum Stamp the checksum of the ConfigMaps and Secrets referenced by a workload on its pod template.
+// When the referenced data change, the workload is rolled out.
+func (c *Controller) EnableConfigChecksum() {
+	c.configChecksumEnabled = true
+}
+
+// AddPolicy Run the policies, in order, on every object of the formation before it is applied.
+func (c *Controller) AddPolicy(policy ...types.Policy) {
+	c.policies = append(c.policies, policy...)
+}
+
+// DisableValidation Skip the validation of the formation done at the beginning of every reconcile.
+// By default an invalid formation is not applied, see Validate for the checks
+func (c *Controller) DisableValidation() {
+	c.validationDisabled = true
+}
+
 func (c Controller) ForObject(object client.Object) *Controller {
-	b := &Controller{cli: c.cli, scheme: c.scheme, object: object, transformers: c.transformers}
+	b := &Controller{cli: c.cli, scheme: c.scheme, object: object, transformers: c.transformers,
+		configChecksumEnabled: c.configChecksumEnabled, validationDisabled: c.validationDisabled, policies: c.policies}
 	return b
 }
 
 func (c Controller) Reconcile(ctx context.Context, list []types.Resource) (result ctrl.Result, err error) {
+	// Reject an invalid formation before applying anything, to avoid leaving it partially applied
+	if !c.validationDisabled {
+		if errs := Validate(list); len(errs) > 0 {
+			err := &ValidationError{Errors: errs}
+			log.Error().Err(err).Msg("formation validation failed")
+			return ctrl.Result{}, err
+		}
+	}
+
 	status, err := c.GetStatus()
 	if err != nil {
 		return ctrl.Result{}, err
@@ -74,6 +109,15 @@ func (c Controller) Reconcile(ctx context.Context, list []types.Resource) (resul
 		statusMap[key] = status.Resources[idx]
 	}
 
+	if c.versionedNames, err = versionedNames(list); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	c.patches = c.GetPatches()
+	if err := checkPatches(c.patches, list); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	resourceMap := map[string]types.Resource{}
 	//Go over each resource and check if it exists in the status, if not, add.
 	//This task need to be done every reconcile as this list might be outdated on the next call.
@@ -160,12 +204,22 @@ func (c Controller) Reconcile(ctx context.Context, list []types.Resource) (resul
 			// For each resource, delete it from the API server
 			// Multiple resources can be returned if the resource has multiple versions
 			for _, res := range resources {
+				// Resources marked as retained survive the removal from the formation
+				if err := c.cli.Get(ctx, client.ObjectKeyFromObject(res), res); err == nil &&
+					strings.ToLower(res.GetAnnotations()[types.RetainKey]) == "true" {
+					log.Info().Str("name", res.GetName()).Str("kind", res.GetKind()).Msg("resource retained")
+					continue
+				}
 				err := c.cli.Delete(ctx, res)
 				// The resource could still be in use, or we don't have permission to delete it
 				if err != nil && !errors.IsNotFound(err) {
 					log.Error().Caller().Err(err).Msg("unable to delete resource")
 					continue
 				}
+				// A Versioned resource is created under its versioned names only
+				if err := c.deleteVersions(ctx, res); err != nil {
+					log.Error().Caller().Err(err).Msg("unable to delete the versions of the resource")
+				}
 			}
 			// Remove the resource from status if successfully deleted or not found
 			removeResourceFromStatus(status, idx)
@@ -179,8 +233,33 @@ func (c Controller) Reconcile(ctx context.Context, list []types.Resource) (resul
 		}
 		change, err := c.reconcileObject(ctx, resource, c.object, c.object.GetNamespace())
 		if err != nil {
+			// Report the error in the status, the patch is best effort since the reconcile is already failing
+			if res.Message != err.Error() {
+				status.Resources[idx].Message = err.Error()
+				if err := c.cli.Status().Patch(ctx, c.object, client.MergeFrom(copyInstance)); err != nil {
+					log.Error().Caller().Err(err).Msg("unable to update formation status")
+				}
+			}
 			return ctrl.Result{RequeueAfter: time.Second * 10}, err
 		}
+		if res.Message != "" {
+			status.Resources[idx].Message = ""
+			if err := c.cli.Status().Patch(ctx, c.object, client.MergeFrom(copyInstance)); err != nil {
+				log.Error().Caller().Err(err).Msg("unable to update formation status")
+				return ctrl.Result{}, err
+			}
+			copyInstance = c.object.DeepCopyObject().(client.Object)
+		}
+		if expiration, ok := resource.(types.Expiration); ok {
+			if t := expiration.Expiration(); t != nil && !t.Equal(res.Expiration) {
+				status.Resources[idx].Expiration = t.DeepCopy()
+				if err := c.cli.Status().Patch(ctx, c.object, client.MergeFrom(copyInstance)); err != nil {
+					log.Error().Caller().Err(err).Msg("unable to update formation status")
+					return ctrl.Result{}, err
+				}
+				copyInstance = c.object.DeepCopyObject().(client.Object)
+			}
+		}
 
 		//Check if this resource is a part of ConvergedGroupInterface
 		currentGroup := 0
@@ -263,7 +342,28 @@ func (c Controller) Reconcile(ctx context.Context, list []types.Resource) (resul
 			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 		}
 	}
-	return ctrl.Result{}, nil
+	return requeueAtDeadline(list), nil
+}
+
+// requeueAtDeadline requeue the owner at the earliest deadline of the resources, e.g. the rotation of a Secret.
+// A deadline already passed is retried after a second
+func requeueAtDeadline(list []types.Resource) ctrl.Result {
+	var earliest *v1.Time
+	for _, res := range list {
+		if deadline, ok := res.(types.Deadline); ok {
+			if t := deadline.Deadline(); t != nil && (earliest == nil || t.Before(earliest)) {
+				earliest = t
+			}
+		}
+	}
+	if earliest == nil {
+		return ctrl.Result{}
+	}
+	after := time.Until(earliest.Time)
+	if after < time.Second {
+		after = time.Second
+	}
+	return ctrl.Result{RequeueAfter: after}
 }
 
 // Check if all previous resources are ready
@@ -309,19 +409,40 @@ func (c Controller) ReconcileObject(ctx context.Context, resource types.Resource
 }
 
 func (c Controller) createRuntimeObject(ctx context.Context, resource types.Resource, owner v1.Object, namespace string) (client.Object, error) {
-	obj, err := resource.Create()
-	obj.SetNamespace(namespace)
+	created, err := resource.Create()
 	if err != nil {
 		log.Error().Caller().Err(err).Send()
 		return nil, err
 	}
-	if err := controllerutil.SetOwnerReference(owner, obj, c.scheme); err != nil {
+	// The object is usually held by the resource, it is copied so the changes below do not leak into the resource
+	obj := created.DeepCopyObject().(client.Object)
+	// The patches of the owner are applied first, the namespace, the owner reference and the annotations below can not be patched
+	if err := c.applyPatches(resource, obj); err != nil {
 		log.Error().Caller().Err(err).Send()
 		return nil, err
 	}
+	if isClusterScoped(resource) {
+		// Cluster scoped resources can not be owned by a namespaced owner, the formation status is used to delete them
+		obj.SetNamespace("")
+	} else {
+		obj.SetNamespace(namespace)
+		if err := controllerutil.SetOwnerReference(owner, obj, c.scheme); err != nil {
+			log.Error().Caller().Err(err).Send()
+			return nil, err
+		}
+	}
 	if obj.GetAnnotations() == nil {
 		obj.SetAnnotations(map[string]string{})
 	}
+	c.renameVersionedReferences(obj)
+	if c.configChecksumEnabled {
+		if err := c.stampConfigChecksum(ctx, obj, namespace); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyPolicies(ctx, obj); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
@@ -330,7 +451,8 @@ func (c Controller) getUnstructuredObjects(res *types.ResourceStatus) []*unstruc
 	var resources []*unstructured.Unstructured
 
 	for t := range c.scheme.AllKnownTypes() {
-		if t.Kind == res.Type && t.Group == res.Group {
+		// The type of a resource is usually the kind in lower case
+		if strings.EqualFold(t.Kind, res.Type) && t.Group == res.Group {
 			resources = append(resources, c.getUnstructuredObject(res, &t))
 			break
 		}
@@ -360,8 +482,12 @@ func (c Controller) reconcileObject(ctx context.Context, resource types.Resource
 	}
 	// get the resource from the API server
 	instance := resource.Runtime()
+	key := client.ObjectKey{Name: resource.Name(), Namespace: namespace}
+	if isClusterScoped(resource) {
+		key.Namespace = ""
+	}
 
-	if err := c.cli.Get(ctx, client.ObjectKey{Name: resource.Name(), Namespace: namespace}, instance); err != nil {
+	if err := c.cli.Get(ctx, key, instance); err != nil {
 		if errors.IsNotFound(err) {
 			obj, err := c.createRuntimeObject(ctx, resource, owner, namespace)
 			if err != nil {
@@ -459,3 +585,17 @@ func (c Controller) GetStatus() (*types.FormationStatus, error) {
 	ptrToY := unsafe.Pointer(value.UnsafeAddr())
 	return (*types.FormationStatus)(ptrToY), nil
 }
+
+// GetPatches returns the patches of the owner, spec.patches is used if the owner does not implement FormationPatchesInterface.
+// The owner without patches returns nil
+func (c Controller) GetPatches() []types.Patch {
+	if patches, ok := c.object.(types.FormationPatchesInterface); ok {
+		return patches.GetPatches()
+	}
+	value, err := utils.GetValue2(c.object, "Spec.Patches")
+	if err != nil || !value.CanInterface() {
+		return nil
+	}
+	patches, _ := value.Interface().([]types.Patch)
+	return patches
+}
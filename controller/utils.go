@@ -1,10 +1,21 @@
 package controller
 
 import (
+	"context"
 	"fmt"
-	"github.com/davecgh/go-spew/spew"
 	"hash/fnv"
+	"reflect"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // HashObject returns the hash of a Object hash by a Codec
@@ -19,3 +30,182 @@ func HashObject(obj runtime.Object) string {
 	_, _ = printer.Fprintf(hf, "%#v", obj)
 	return fmt.Sprint(hf.Sum32())
 }
+
+// isClusterScoped returns true if the resource is not namespaced
+func isClusterScoped(resource types.Resource) bool {
+	if scoped, ok := resource.(types.ClusterScoped); ok {
+		return scoped.ClusterScoped()
+	}
+	return false
+}
+
+// versionedNames returns the versioned name of the Versioned resources, the key is <type>/<name> in lower case
+func versionedNames(list []types.Resource) (map[string]string, error) {
+	names := map[string]string{}
+	for _, res := range list {
+		if versioned, ok := res.(types.Versioned); ok {
+			name, err := versioned.VersionedName()
+			if err != nil {
+				return nil, err
+			}
+			names[strings.ToLower(res.Type())+"/"+res.Name()] = name
+		}
+	}
+	return names, nil
+}
+
+// renameVersionedReferences rewrite the references of the pod spec to the versioned name of the formation resources
+func (c Controller) renameVersionedReferences(obj client.Object) {
+	spec := utils.PodSpec(obj)
+	if spec == nil || len(c.versionedNames) == 0 {
+		return
+	}
+	utils.RenamePodSpecReferences(spec, func(kind, name string) string {
+		if versioned, ok := c.versionedNames[kind+"/"+name]; ok {
+			return versioned
+		}
+		return name
+	})
+}
+
+// deleteVersions delete the versions of a Versioned resource removed from the formation,
+// they are labeled with the hash of their name in types.ImmutableNameKey. The retained versions and the versions of other owners are kept
+func (c Controller) deleteVersions(ctx context.Context, obj *unstructured.Unstructured) error {
+	// Only the immutable ConfigMaps and Secrets are versioned
+	if obj.GetKind() != "ConfigMap" && obj.GetKind() != "Secret" {
+		return nil
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(obj.GroupVersionKind().GroupVersion().WithKind(obj.GetKind() + "List"))
+	if err := c.cli.List(ctx, list, client.InNamespace(obj.GetNamespace()),
+		client.MatchingLabels{types.ImmutableNameKey: utils.HashLabelValue(obj.GetName())}); err != nil {
+		return err
+	}
+	for idx := range list.Items {
+		item := &list.Items[idx]
+		if !ownedBy(item, c.object) || strings.ToLower(item.GetAnnotations()[types.RetainKey]) == "true" {
+			continue
+		}
+		if err := c.cli.Delete(ctx, item); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedBy returns true if the owner is one of the owner references of the object
+func ownedBy(obj client.Object, owner client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPatches reject the patches with an invalid target and the patches targeting a resource implementing types.Reconcile,
+// those resources apply their object themselves and the patches would be ignored. The targets matching no resource are logged.
+// The patches are applied and checked by applyPatches
+func checkPatches(patches []types.Patch, list []types.Resource) error {
+	for _, patch := range patches {
+		matched := false
+		for _, res := range list {
+			match, err := utils.PatchTargetMatch(patch.Target, res.Type(), res.Name())
+			if err != nil {
+				return err
+			}
+			if !match {
+				continue
+			}
+			if _, ok := res.(types.Reconcile); ok {
+				return fmt.Errorf("patch %s can not target %s/%s, it reconciles its object itself", patch.Target, res.Type(), res.Name())
+			}
+			matched = true
+		}
+		if !matched {
+			log.Warn().Str("target", patch.Target).Msg("patch does not match any resource")
+		}
+	}
+	return nil
+}
+
+// identity is the part of an object managed by the formation that the patches can not change
+type identity struct {
+	name            string
+	namespace       string
+	ownerReferences []metav1.OwnerReference
+	annotations     map[string]string
+}
+
+func identityOf(obj client.Object) identity {
+	return identity{
+		name:            obj.GetName(),
+		namespace:       obj.GetNamespace(),
+		ownerReferences: obj.GetOwnerReferences(),
+		annotations:     formationAnnotations(obj),
+	}
+}
+
+// formationAnnotations returns the annotations of the object with the formation/ prefix, e.g. types.RetainKey
+func formationAnnotations(obj client.Object) map[string]string {
+	annotations := map[string]string{}
+	for key, value := range obj.GetAnnotations() {
+		if strings.HasPrefix(key, "formation/") {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// applyPatches apply in place the patches of the owner targeting the resource. A patch changing the name, the namespace,
+// the owner references or the formation annotations of the object is rejected
+func (c Controller) applyPatches(resource types.Resource, obj client.Object) error {
+	for _, patch := range c.patches {
+		match, err := utils.PatchTargetMatch(patch.Target, resource.Type(), resource.Name())
+		if err != nil {
+			return err
+		}
+		if !match {
+			continue
+		}
+		before := identityOf(obj)
+		if err := utils.ApplyPatch(obj, patch); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(before, identityOf(obj)) {
+			return fmt.Errorf("patch %s can not change the name, the namespace, the owner references nor the formation annotations of %s/%s",
+				patch.Target, resource.Type(), resource.Name())
+		}
+	}
+	return nil
+}
+
+// PolicyViolation is returned when a policy reject an object of the formation
+type PolicyViolation struct {
+	Policy string
+	Kind   string
+	Name   string
+	Reason error
+}
+
+func (e *PolicyViolation) Error() string {
+	return fmt.Sprintf("policy %s rejected %s %s: %s", e.Policy, e.Kind, e.Name, e.Reason)
+}
+
+func (e *PolicyViolation) Unwrap() error {
+	return e.Reason
+}
+
+// applyPolicies run the policies of the controller on the object, the first rejection is returned
+func (c Controller) applyPolicies(ctx context.Context, obj client.Object) error {
+	for _, policy := range c.policies {
+		if err := policy.Apply(ctx, obj); err != nil {
+			kind := obj.GetObjectKind().GroupVersionKind().Kind
+			if kinds, _, kindErr := c.scheme.ObjectKinds(obj); kindErr == nil && len(kinds) > 0 {
+				kind = kinds[0].Kind
+			}
+			return &PolicyViolation{Policy: policy.Name(), Kind: kind, Name: obj.GetName(), Reason: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidationError is returned by Reconcile when the formation is not valid, nothing is applied
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return "invalid formation: " + strings.Join(messages, "; ")
+}
+
+// Validate check the formation for mistakes that would otherwise be rejected by the API server in the middle of a reconcile:
+//   - duplicate type/name
+//   - invalid names
+//   - ConfigMaps, Secrets, PersistentVolumeClaims and ServiceAccounts referenced by a pod and not in the formation.
+//     The optional ConfigMaps and Secrets are skipped. References not managed by the formation are listed by the resources implementing types.ExternalReferences
+//   - duplicate environment variables in a container, duplicate port names in a pod or a service
+//   - selector not matching the labels of the pod template
+//   - containers without image
+//
+// The objects of the resources implementing types.Reconcile are not created, they may generate their data on Create.
+// Only their type and name are checked
+func Validate(list []types.Resource) []error {
+	var errs []error
+	keys := map[string]struct{}{}
+	objects := map[string]client.Object{}
+	external := map[string]map[string]struct{}{}
+	var order []string
+	for _, res := range list {
+		key := strings.ToLower(res.Type()) + "/" + res.Name()
+		if _, ok := keys[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate resource", key))
+			continue
+		}
+		keys[key] = struct{}{}
+		for _, msg := range validateName(strings.ToLower(res.Type()), res.Name()) {
+			errs = append(errs, fmt.Errorf("%s: invalid name: %s", key, msg))
+		}
+		if _, ok := res.(types.Reconcile); ok {
+			continue
+		}
+		obj, err := res.Create()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		objects[key] = obj
+		external[key] = externalReferences(res)
+		order = append(order, key)
+	}
+
+	for _, key := range order {
+		obj := objects[key]
+		var objErrs []string
+		if service, ok := obj.(*v1.Service); ok {
+			objErrs = append(objErrs, duplicates("service port", servicePortNames(service))...)
+		}
+		if template := utils.PodTemplate(obj); template != nil {
+			objErrs = append(objErrs, validatePodSpec(&template.Spec)...)
+			objErrs = append(objErrs, validateReferences(&template.Spec, keys, external[key])...)
+			if selector := workloadSelector(obj); selector != nil {
+				s, err := metav1.LabelSelectorAsSelector(selector)
+				if err != nil {
+					objErrs = append(objErrs, fmt.Sprintf("invalid selector: %s", err))
+				} else if s.Empty() || !s.Matches(labels.Set(template.Labels)) {
+					objErrs = append(objErrs, fmt.Sprintf("selector %s does not match the pod template labels", s.String()))
+				}
+			}
+		}
+		for _, msg := range objErrs {
+			errs = append(errs, fmt.Errorf("%s: %s", key, msg))
+		}
+	}
+	return errs
+}
+
+// validateName returns the reasons why the name is invalid for the type
+func validateName(resourceType, name string) []string {
+	switch resourceType {
+	case "service":
+		return validation.IsDNS1035Label(name)
+	case "role", "rolebinding", "clusterrole", "clusterrolebinding":
+		if name == "" {
+			return []string{"name can not be empty"}
+		}
+		return path.IsValidPathSegmentName(name)
+	}
+	return validation.IsDNS1123Subdomain(name)
+}
+
+// workloadSelector returns the selector of the workload, nil if it is generated
+func workloadSelector(obj client.Object) *metav1.LabelSelector {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Selector
+	case *appsv1.StatefulSet:
+		return o.Spec.Selector
+	case *appsv1.DaemonSet:
+		return o.Spec.Selector
+	case *appsv1.ReplicaSet:
+		return o.Spec.Selector
+	case *batchv1.Job:
+		if o.Spec.ManualSelector != nil && *o.Spec.ManualSelector {
+			return o.Spec.Selector
+		}
+	}
+	return nil
+}
+
+func validatePodSpec(spec *v1.PodSpec) []string {
+	var errs []string
+	var portNames []string
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		if container.Image == "" {
+			errs = append(errs, fmt.Sprintf("container %s has no image", container.Name))
+		}
+		envNames := make([]string, 0, len(container.Env))
+		for _, env := range container.Env {
+			envNames = append(envNames, env.Name)
+		}
+		for _, msg := range duplicates("environment variable", envNames) {
+			errs = append(errs, fmt.Sprintf("container %s: %s", container.Name, msg))
+		}
+		for _, port := range container.Ports {
+			if port.Name != "" {
+				portNames = append(portNames, port.Name)
+			}
+		}
+	}
+	return append(errs, duplicates("port", portNames)...)
+}
+
+// validateReferences returns the objects required by the pod spec that are neither in the formation nor external
+func validateReferences(spec *v1.PodSpec, keys, external map[string]struct{}) []string {
+	var errs []string
+	for _, key := range requiredReferences(spec) {
+		if _, ok := keys[key]; ok {
+			continue
+		}
+		if _, ok := external[key]; ok {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("%s not found in the formation", key))
+	}
+	return errs
+}
+
+// requiredReferences returns the <type>/<name> of the objects referenced by the pod spec, in order and without duplicates.
+// The optional ConfigMaps and Secrets are skipped, the pod starts without them
+func requiredReferences(spec *v1.PodSpec) []string {
+	var refs []string
+	seen := map[string]struct{}{}
+	add := func(kind, name string, optional *bool) {
+		key := kind + "/" + name
+		if _, ok := seen[key]; ok || (optional != nil && *optional) {
+			return
+		}
+		seen[key] = struct{}{}
+		refs = append(refs, key)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			add("configmap", volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		}
+		if volume.Secret != nil {
+			add("secret", volume.Secret.SecretName, volume.Secret.Optional)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			add("persistentvolumeclaim", volume.PersistentVolumeClaim.ClaimName, nil)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add("configmap", source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					add("secret", source.Secret.Name, source.Secret.Optional)
+				}
+			}
+		}
+	}
+	for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.ConfigMapRef != nil {
+					add("configmap", envFrom.ConfigMapRef.Name, envFrom.ConfigMapRef.Optional)
+				}
+				if envFrom.SecretRef != nil {
+					add("secret", envFrom.SecretRef.Name, envFrom.SecretRef.Optional)
+				}
+			}
+			for _, env := range container.Env {
+				if env.ValueFrom == nil {
+					continue
+				}
+				if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+					add("configmap", ref.Name, ref.Optional)
+				}
+				if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+					add("secret", ref.Name, ref.Optional)
+				}
+			}
+		}
+	}
+	if spec.ServiceAccountName != "" && spec.ServiceAccountName != "default" {
+		add("serviceaccount", spec.ServiceAccountName, nil)
+	}
+	return refs
+}
+
+// externalReferences returns the <type>/<name> of the resource implementing types.ExternalReferences
+func externalReferences(res types.Resource) map[string]struct{} {
+	external := map[string]struct{}{}
+	refs, ok := res.(types.ExternalReferences)
+	if !ok {
+		return external
+	}
+	for _, item := range refs.ExternalReferences() {
+		if item = strings.TrimSpace(item); item != "" {
+			external[strings.ToLower(item)] = struct{}{}
+		}
+	}
+	return external
+}
+
+func servicePortNames(service *v1.Service) []string {
+	names := make([]string, 0, len(service.Spec.Ports))
+	for _, port := range service.Spec.Ports {
+		if port.Name != "" {
+			names = append(names, port.Name)
+		}
+	}
+	return names
+}
+
+// duplicates returns a message for each name present more than once
+func duplicates(what string, names []string) []string {
+	var errs []string
+	count := map[string]int{}
+	for _, name := range names {
+		count[name]++
+		if count[name] == 2 {
+			errs = append(errs, fmt.Sprintf("duplicate %s %s", what, name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,177 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PolicyFunc is a types.Policy implemented by a function
+type PolicyFunc struct {
+	PolicyName string
+	Func       func(ctx context.Context, obj client.Object) error
+}
+
+func (p PolicyFunc) Name() string { return p.PolicyName }
+
+func (p PolicyFunc) Apply(ctx context.Context, obj client.Object) error {
+	return p.Func(ctx, obj)
+}
+
+// NewPolicy Create a policy from a function
+func NewPolicy(name string, fn func(ctx context.Context, obj client.Object) error) types.Policy {
+	return PolicyFunc{PolicyName: name, Func: fn}
+}
+
+// NewContainerPolicy Create a policy running the function on every container, init containers included, of the pod spec of the object.
+// The objects without a pod spec are ignored
+func NewContainerPolicy(name string, fn func(container *v1.Container) error) types.Policy {
+	return NewPolicy(name, func(_ context.Context, obj client.Object) error {
+		spec := utils.PodSpec(obj)
+		if spec == nil {
+			return nil
+		}
+		for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+			for idx := range containers {
+				if err := fn(&containers[idx]); err != nil {
+					return fmt.Errorf("container %s: %w", containers[idx].Name, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// NoLatestTag reject the images without tag or with the latest tag, images pinned by digest are accepted
+func NoLatestTag() types.Policy {
+	return NewContainerPolicy("no-latest-tag", func(container *v1.Container) error {
+		ref, err := utils.ParseImageRef(container.Image)
+		if err != nil {
+			return err
+		}
+		if ref.Digest != "" {
+			return nil
+		}
+		if ref.Tag == "" {
+			return fmt.Errorf("image %s has no tag", container.Image)
+		}
+		if ref.Tag == "latest" {
+			return fmt.Errorf("image %s use the latest tag", container.Image)
+		}
+		return nil
+	})
+}
+
+// RequireResourceLimits reject the containers without limits for the resources, default to cpu and memory
+func RequireResourceLimits(resources ...v1.ResourceName) types.Policy {
+	if len(resources) == 0 {
+		resources = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+	}
+	return NewContainerPolicy("require-resource-limits", func(container *v1.Container) error {
+		for _, resource := range resources {
+			if _, ok := container.Resources.Limits[resource]; !ok {
+				return fmt.Errorf("missing %s limit", resource)
+			}
+		}
+		return nil
+	})
+}
+
+// DefaultResources set the requests and limits missing from the containers
+func DefaultResources(defaults v1.ResourceRequirements) types.Policy {
+	return NewContainerPolicy("default-resources", func(container *v1.Container) error {
+		for name, quantity := range defaults.Requests {
+			if _, ok := container.Resources.Requests[name]; !ok {
+				if container.Resources.Requests == nil {
+					container.Resources.Requests = v1.ResourceList{}
+				}
+				container.Resources.Requests[name] = quantity.DeepCopy()
+			}
+		}
+		for name, quantity := range defaults.Limits {
+			if _, ok := container.Resources.Limits[name]; !ok {
+				if container.Resources.Limits == nil {
+					container.Resources.Limits = v1.ResourceList{}
+				}
+				container.Resources.Limits[name] = quantity.DeepCopy()
+			}
+		}
+		return nil
+	})
+}
+
+// RegistryPrefix reject the images not in one of the registry or registry/repository prefixes, e.g. registry.example.com
+// or quay.io/org. The images and the prefixes are normalized, nginx match docker.io/library/nginx.
+// Without prefix, every image is rejected
+func RegistryPrefix(prefixes ...string) types.Policy {
+	normalized := make([]string, len(prefixes))
+	for idx, prefix := range prefixes {
+		normalized[idx] = utils.NormalizeImagePrefix(prefix)
+	}
+	return NewContainerPolicy("registry-prefix", func(container *v1.Container) error {
+		if len(normalized) == 0 {
+			return fmt.Errorf("image %s rejected, no registry is allowed", container.Image)
+		}
+		ref, err := utils.ParseImageRef(container.Image)
+		if err != nil {
+			return err
+		}
+		for _, prefix := range normalized {
+			if ref.HasPrefix(prefix) {
+				return nil
+			}
+		}
+		return fmt.Errorf("image %s is not from an allowed registry (%s)", container.Image, strings.Join(prefixes, ", "))
+	})
+}
+
+// InjectLabels add the labels to the object and to its pod template, existing labels are not overridden
+func InjectLabels(labels map[string]string) types.Policy {
+	return NewPolicy("inject-labels", func(_ context.Context, obj client.Object) error {
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = map[string]string{}
+		}
+		addMissing(objLabels, labels)
+		obj.SetLabels(objLabels)
+		if template := utils.PodTemplate(obj); template != nil {
+			if template.Labels == nil {
+				template.Labels = map[string]string{}
+			}
+			addMissing(template.Labels, labels)
+		}
+		return nil
+	})
+}
+
+func addMissing(dest, src map[string]string) {
+	for k, v := range src {
+		if _, ok := dest[k]; !ok {
+			dest[k] = v
+		}
+	}
+}
+
+// RequirePodSecurity reject the pods not satisfying the Pod Security Standards profile
+func RequirePodSecurity(profile types.SecurityProfile) types.Policy {
+	return NewPolicy("pod-security-"+string(profile), func(_ context.Context, obj client.Object) error {
+		spec := utils.PodSpec(obj)
+		if spec == nil {
+			return nil
+		}
+		report := utils.CheckPodSecurity(spec)
+		if report.Satisfies(profile) {
+			return nil
+		}
+		violations := report.Baseline
+		if profile == types.SecurityProfileRestricted {
+			violations = append(violations, report.Restricted...)
+		}
+		return fmt.Errorf("pod does not satisfy the %s profile: %s", profile, strings.Join(violations, ", "))
+	})
+}
@@ -11,6 +11,11 @@ import (
 type Deployment struct {
 	*common.SimpleResource[*v1.Deployment]
 	WaitForConverged bool
+	// IgnoreReplicas the replicas are managed by something else (e.g. HorizontalPodAutoscaler).
+	// Spec.Replicas is not enforced, the value on the API server is kept.
+	IgnoreReplicas bool
+	// External are the <type>/<name> of the objects referenced by the pods that are not part of the formation
+	External []string
 }
 
 func NewDeployment(deployment *v1.Deployment) *Deployment {
@@ -20,6 +25,23 @@ func NewDeployment(deployment *v1.Deployment) *Deployment {
 	}
 }
 
+// ExternalReferences returns the objects referenced by the pods that are not part of the formation
+func (c *Deployment) ExternalReferences() []string {
+	return c.External
+}
+
+func (c *Deployment) Create() (client.Object, error) {
+	obj, err := c.SimpleResource.Create()
+	if err != nil || !c.IgnoreReplicas {
+		return obj, err
+	}
+	// A nil pointer is never merged into the object from the API server,
+	// it is set on a copy so the replicas of the object are kept
+	copied := obj.(*v1.Deployment).DeepCopy()
+	copied.Spec.Replicas = nil
+	return copied, nil
+}
+
 func (c *Deployment) Converged(ctx context.Context, cli client.Client, namespace string) (bool, error) {
 	if !c.WaitForConverged {
 		return true, nil
@@ -11,6 +11,11 @@ import (
 type StatefulSet struct {
 	*common.SimpleResource[*v1.StatefulSet]
 	WaitForConverged bool
+	// IgnoreReplicas the replicas are managed by something else (e.g. HorizontalPodAutoscaler).
+	// Spec.Replicas is not enforced, the value on the API server is kept.
+	IgnoreReplicas bool
+	// External are the <type>/<name> of the objects referenced by the pods that are not part of the formation
+	External []string
 }
 
 func NewStatefulSet(statefulSet *v1.StatefulSet) *StatefulSet {
@@ -20,6 +25,23 @@ func NewStatefulSet(statefulSet *v1.StatefulSet) *StatefulSet {
 	}
 }
 
+// ExternalReferences returns the objects referenced by the pods that are not part of the formation
+func (c *StatefulSet) ExternalReferences() []string {
+	return c.External
+}
+
+func (c *StatefulSet) Create() (client.Object, error) {
+	obj, err := c.SimpleResource.Create()
+	if err != nil || !c.IgnoreReplicas {
+		return obj, err
+	}
+	// A nil pointer is never merged into the object from the API server,
+	// it is set on a copy so the replicas of the object are kept
+	copied := obj.(*v1.StatefulSet).DeepCopy()
+	copied.Spec.Replicas = nil
+	return copied, nil
+}
+
 func (c *StatefulSet) Converged(ctx context.Context, cli client.Client, namespace string) (bool, error) {
 	if !c.WaitForConverged {
 		return true, nil
@@ -0,0 +1,16 @@
+package autoscaling
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	v2 "k8s.io/api/autoscaling/v2"
+)
+
+type HorizontalPodAutoscaler struct {
+	*common.SimpleResource[*v2.HorizontalPodAutoscaler]
+}
+
+func NewHorizontalPodAutoscaler(hpa *v2.HorizontalPodAutoscaler) *HorizontalPodAutoscaler {
+	return &HorizontalPodAutoscaler{
+		SimpleResource: common.NewSimpleResource("horizontalpodautoscaler", hpa),
+	}
+}
@@ -13,6 +13,8 @@ import (
 type Job struct {
 	*common.SimpleResource[*v1.Job]
 	WaitForConverged bool
+	// External are the <type>/<name> of the objects referenced by the pods that are not part of the formation
+	External []string
 }
 
 func NewJob(job *v1.Job) *Job {
@@ -21,6 +23,12 @@ func NewJob(job *v1.Job) *Job {
 		WaitForConverged: true,
 	}
 }
+
+// ExternalReferences returns the objects referenced by the pods that are not part of the formation
+func (c *Job) ExternalReferences() []string {
+	return c.External
+}
+
 func (c *Job) Create() (client.Object, error) {
 	//Once a Job is created, it is not possible to update it.
 	if c.Obj.Annotations == nil {
@@ -37,6 +37,10 @@ func (s *SimpleResource[T]) Runtime() client.Object {
 	}
 	return reflect.New(t).Elem().Addr().Interface().(client.Object)
 }
+
+// Object returns the object of the resource, it is shared with the next calls of Create
+func (s *SimpleResource[T]) Object() client.Object { return s.Obj }
+
 func (s *SimpleResource[T]) Create() (client.Object, error) {
 	if s.DisableUpdate {
 		if s.Obj.GetAnnotations() == nil {
@@ -49,3 +53,11 @@ func (s *SimpleResource[T]) Create() (client.Object, error) {
 	}
 	return s.Obj, nil
 }
+
+// Object returns the object shared by the resource if it implements types.SharedObject, the one returned by Create otherwise
+func Object(res types.Resource) (client.Object, error) {
+	if shared, ok := res.(types.SharedObject); ok {
+		return shared.Object(), nil
+	}
+	return res.Create()
+}
@@ -0,0 +1,200 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	TLSCACertKey = "ca.crt"
+	TLSCAKeyKey  = "ca.key"
+)
+
+// TLSGenerator generate a self-signed CA and a leaf certificate signed by it.
+// The keys follow the kubernetes.io/tls Secret type: ca.crt, ca.key, tls.crt and tls.key.
+type TLSGenerator struct {
+	CommonName string
+	// DNSNames the subject alternative names of the leaf certificate
+	DNSNames []string
+	// ServiceNames the names of the services to add to the subject alternative names.
+	// Each service is expanded to <name>, <name>.<namespace>, <name>.<namespace>.svc and <name>.<namespace>.svc.<ClusterDomain>
+	ServiceNames []string
+	// ClusterDomain default to cluster.local
+	ClusterDomain string
+	IPAddresses   []net.IP
+	// Validity of the leaf certificate, default to 1 year
+	Validity time.Duration
+	// CAValidity of the CA certificate, default to 10 years
+	CAValidity time.Duration
+}
+
+func (g *TLSGenerator) Keys() []string {
+	return []string{TLSCACertKey, TLSCAKeyKey, v1.TLSCertKey, v1.TLSPrivateKeyKey}
+}
+
+// Generate a new leaf certificate. The existing CA is reused if it is still valid after the leaf certificate expire
+func (g *TLSGenerator) Generate(namespace string, existing map[string][]byte) (map[string][]byte, error) {
+	now := time.Now()
+	validity := g.Validity
+	if validity <= 0 {
+		validity = 365 * 24 * time.Hour
+	}
+	caCert, caKey, err := parseCA(existing)
+	if err != nil || caCert.NotAfter.Before(now.Add(validity)) {
+		caCert, caKey, err = g.generateCA(now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: g.CommonName},
+		DNSNames:     g.dnsNames(namespace),
+		IPAddresses:  g.IPAddresses,
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := encodeECKey(key)
+	if err != nil {
+		return nil, err
+	}
+	caKeyPem, err := encodeECKey(caKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		TLSCACertKey:        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}),
+		TLSCAKeyKey:         caKeyPem,
+		v1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		v1.TLSPrivateKeyKey: keyPem,
+	}, nil
+}
+
+// Expiration returns the earliest expiration of the CA and the leaf certificate
+func (g *TLSGenerator) Expiration(data map[string][]byte) *time.Time {
+	var expiration *time.Time
+	for _, key := range []string{TLSCACertKey, v1.TLSCertKey} {
+		cert, err := parseCertificate(data[key])
+		if err != nil {
+			continue
+		}
+		if expiration == nil || cert.NotAfter.Before(*expiration) {
+			notAfter := cert.NotAfter
+			expiration = &notAfter
+		}
+	}
+	return expiration
+}
+
+func (g *TLSGenerator) dnsNames(namespace string) []string {
+	names := append([]string{}, g.DNSNames...)
+	domain := g.ClusterDomain
+	if domain == "" {
+		domain = "cluster.local"
+	}
+	for _, service := range g.ServiceNames {
+		names = append(names, service)
+		if namespace != "" {
+			names = append(names,
+				service+"."+namespace,
+				service+"."+namespace+".svc",
+				service+"."+namespace+".svc."+domain)
+		}
+	}
+	return names
+}
+
+func (g *TLSGenerator) generateCA(now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	validity := g.CAValidity
+	if validity <= 0 {
+		validity = 10 * 365 * 24 * time.Hour
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	commonName := g.CommonName + " CA"
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func parseCA(data map[string][]byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	cert, err := parseCertificate(data[TLSCACertKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data[TLSCAKeyKey])
+	if block == nil {
+		return nil, nil, errors.New("unable to decode the CA key")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("unable to decode the certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -1,10 +1,16 @@
 package core
 
 import (
+	"fmt"
+
 	"github.com/davidboxer/formation/resources/common"
 	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// MaxConfigMapSize is the maximum size of the data stored in a ConfigMap (1 MiB)
+const MaxConfigMapSize = 1024 * 1024
+
 type ConfigMap struct {
 	*common.SimpleResource[*v1.ConfigMap]
 }
@@ -20,3 +26,27 @@ func NewConfigMapWithOnCreate(configMap *v1.ConfigMap, onCreate func(*v1.ConfigM
 		SimpleResource: common.NewSimpleResourceWithOnCreate("configmap", configMap, onCreate),
 	}
 }
+
+// Create the ConfigMap, an error is returned if the data is larger than MaxConfigMapSize
+func (c *ConfigMap) Create() (client.Object, error) {
+	obj, err := c.SimpleResource.Create()
+	if err != nil {
+		return nil, err
+	}
+	if size := ConfigMapDataSize(c.Obj); size > MaxConfigMapSize {
+		return nil, fmt.Errorf("configmap %s is too large: %d bytes, maximum is %d bytes", c.Obj.Name, size, MaxConfigMapSize)
+	}
+	return obj, nil
+}
+
+// ConfigMapDataSize returns the size in bytes of the keys and values of the ConfigMap
+func ConfigMapDataSize(configMap *v1.ConfigMap) int {
+	size := 0
+	for k, v := range configMap.Data {
+		size += len(k) + len(v)
+	}
+	for k, v := range configMap.BinaryData {
+		size += len(k) + len(v)
+	}
+	return size
+}
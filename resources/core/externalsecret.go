@@ -0,0 +1,277 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// KubernetesSecretSourceName is the default source name of an ExternalSecretReference
+const KubernetesSecretSourceName = "kubernetes"
+
+// SecretSource provide the data of a secret living outside the formation
+type SecretSource interface {
+	// Fetch returns the data of the secret identified by the reference
+	Fetch(ctx context.Context, cli client.Client, ref types.ExternalSecretReference) (map[string][]byte, error)
+}
+
+// KubernetesSecretSource read a Secret from another namespace.
+// Only the namespaces of AllowedNamespaces can be read, the CRs would otherwise copy any Secret readable by the operator
+type KubernetesSecretSource struct {
+	AllowedNamespaces []string
+}
+
+func (k KubernetesSecretSource) Fetch(ctx context.Context, cli client.Client, ref types.ExternalSecretReference) (map[string][]byte, error) {
+	if ref.Namespace == "" {
+		return nil, fmt.Errorf("external secret %s: the namespace is required", ref.Name)
+	}
+	allowed := false
+	for _, namespace := range k.AllowedNamespaces {
+		allowed = allowed || namespace == ref.Namespace
+	}
+	if !allowed {
+		return nil, fmt.Errorf("external secret %s: namespace %s is not allowed", ref.Name, ref.Namespace)
+	}
+	secret := &v1.Secret{}
+	if err := cli.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, secret); err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+// FileSecretSource read the regular files of the directory <Root>/<name>, each file is a key of the secret.
+// It is typically used with a Secret mounted in the operator pod
+type FileSecretSource struct {
+	Root string
+	// FS if set, the files are read from it instead of the disk
+	FS fs.FS
+}
+
+func (f FileSecretSource) Fetch(_ context.Context, _ client.Client, ref types.ExternalSecretReference) (map[string][]byte, error) {
+	fsys := f.FS
+	if fsys == nil {
+		fsys = os.DirFS(f.Root)
+	}
+	entries, err := fs.ReadDir(fsys, ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(ref.Name, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = content
+	}
+	return data, nil
+}
+
+// EnvSecretSource read the environment variables of the operator starting with <Prefix><name of the reference>.
+// The prefixes are removed from the key, e.g. with the Prefix SECRET_ and the name DB_, SECRET_DB_PASSWORD become PASSWORD.
+// Prefix is required, it restrict the variables readable by the CRs to the ones the operator expose
+type EnvSecretSource struct {
+	Prefix string
+}
+
+func (e EnvSecretSource) Fetch(_ context.Context, _ client.Client, ref types.ExternalSecretReference) (map[string][]byte, error) {
+	if e.Prefix == "" {
+		return nil, fmt.Errorf("external secret %s: the environment source require a prefix", ref.Name)
+	}
+	prefix := e.Prefix + ref.Name
+	data := map[string][]byte{}
+	for _, env := range os.Environ() {
+		name, value, found := strings.Cut(env, "=")
+		if !found || !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		data[strings.TrimPrefix(name, prefix)] = []byte(value)
+	}
+	return data, nil
+}
+
+// VaultSecretSource read a secret from the KV version 2 engine of a Vault-compatible HTTP API.
+// The name of the reference is the path of the secret under PathPrefix, the segments . and .. are rejected
+type VaultSecretSource struct {
+	// Address of the server, e.g. https://vault:8200
+	Address string
+	// Mount path of the KV engine, default to secret
+	Mount string
+	// PathPrefix is required, e.g. operators/my-operator. It restrict the secrets readable by the CRs,
+	// they could otherwise read any secret the token can read
+	PathPrefix string
+	// Token used to authenticate, TokenFile is read on every fetch if Token is empty
+	Token     string
+	TokenFile string
+	// Client default to http.DefaultClient
+	Client *http.Client
+}
+
+func (v VaultSecretSource) Fetch(ctx context.Context, _ client.Client, ref types.ExternalSecretReference) (map[string][]byte, error) {
+	mount := v.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	mount, err := escapeVaultPath(strings.Trim(mount, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("vault mount %s: %w", v.Mount, err)
+	}
+	if v.PathPrefix == "" {
+		return nil, fmt.Errorf("external secret %s: the vault source require a path prefix", ref.Name)
+	}
+	prefix, err := escapeVaultPath(strings.Trim(v.PathPrefix, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("vault path prefix %s: %w", v.PathPrefix, err)
+	}
+	name, err := escapeVaultPath(ref.Name)
+	if err != nil {
+		return nil, fmt.Errorf("external secret %s: %w", ref.Name, err)
+	}
+	token := v.Token
+	if token == "" && v.TokenFile != "" {
+		content, err := os.ReadFile(v.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	address := strings.TrimSuffix(v.Address, "/") + "/v1/" + mount + "/data/" + prefix + "/" + name
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+	httpClient := v.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to read %s from vault: %s", ref.Name, response.Status)
+	}
+	body := struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{}
+	for k, value := range body.Data.Data {
+		data[k] = []byte(value)
+	}
+	return data, nil
+}
+
+// escapeVaultPath escape each segment of the path, the empty, . and .. segments are rejected
+func escapeVaultPath(value string) (string, error) {
+	segments := strings.Split(value, "/")
+	for idx, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("invalid path %q: empty, . and .. segments are not allowed", value)
+		}
+		segments[idx] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// ExternalSecret is a Secret synchronized from a SecretSource into the owner namespace.
+// The source is read on every reconcile and the Secret is updated when the data change.
+type ExternalSecret struct {
+	*common.SimpleResource[*v1.Secret]
+	Source    SecretSource
+	Reference types.ExternalSecretReference
+}
+
+func NewExternalSecret(name string, source SecretSource, ref types.ExternalSecretReference) *ExternalSecret {
+	return &ExternalSecret{
+		SimpleResource: common.NewSimpleResource("secret", &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}}),
+		Source:         source,
+		Reference:      ref,
+	}
+}
+
+func (s *ExternalSecret) Reconcile(ctx context.Context, cli client.Client, owner metav1.Object) (bool, error) {
+	namespace := owner.GetNamespace()
+	data, err := s.Source.Fetch(ctx, cli, s.Reference)
+	if err != nil {
+		return false, fmt.Errorf("unable to fetch external secret %s: %w", s.Reference.Name, err)
+	}
+	if len(s.Reference.Keys) > 0 {
+		filtered := map[string][]byte{}
+		for _, key := range s.Reference.Keys {
+			value, ok := data[key]
+			if !ok {
+				return false, fmt.Errorf("key %s not found in external secret %s", key, s.Reference.Name)
+			}
+			filtered[key] = value
+		}
+		data = filtered
+	}
+	obj, err := s.Create()
+	if err != nil {
+		return false, err
+	}
+	desired := obj.(*v1.Secret).DeepCopy()
+
+	secret := &v1.Secret{}
+	if err := cli.Get(ctx, client.ObjectKey{Name: desired.Name, Namespace: namespace}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		desired.Namespace = namespace
+		desired.Data = data
+		if err := controllerutil.SetOwnerReference(owner, desired, cli.Scheme()); err != nil {
+			return false, err
+		}
+		return true, cli.Create(ctx, desired)
+	}
+	if strings.ToLower(secret.Annotations[types.UpdateKey]) == "disabled" {
+		return false, nil
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	changed := len(secret.Data) != len(data)
+	for k, v := range data {
+		if !bytes.Equal(secret.Data[k], v) {
+			changed = true
+		}
+	}
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		if secret.Labels[k] != v {
+			secret.Labels[k] = v
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	secret.Data = data
+	return true, cli.Patch(ctx, secret, patch)
+}
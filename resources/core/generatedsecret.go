@@ -0,0 +1,184 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// GeneratedSecret is a Secret with data generated by SecretGenerator.
+// The data is generated once and preserved on every reconcile, only the missing keys are generated.
+// The static data of the Secret is always enforced.
+type GeneratedSecret struct {
+	*common.SimpleResource[*v1.Secret]
+	Generators []SecretGenerator
+	// RotateBefore If set, the data of the generators implementing SecretExpiration is regenerated
+	// when it expires in less than RotateBefore. The owner is requeued to rotate it on time, see Deadline.
+	RotateBefore time.Duration
+
+	expiration *metav1.Time
+}
+
+func NewGeneratedSecret(secret *v1.Secret, generators ...SecretGenerator) *GeneratedSecret {
+	if secret == nil {
+		secret = &v1.Secret{}
+	}
+	return &GeneratedSecret{
+		SimpleResource: common.NewSimpleResource("secret", secret),
+		Generators:     generators,
+	}
+}
+
+// NewTLSSecret create a kubernetes.io/tls Secret with a self-signed CA and a leaf certificate
+func NewTLSSecret(name string, generator *TLSGenerator, rotateBefore time.Duration) *GeneratedSecret {
+	s := NewGeneratedSecret(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Type:       v1.SecretTypeTLS,
+	}, generator)
+	s.RotateBefore = rotateBefore
+	return s
+}
+
+// Expiration returns the earliest expiration of the generated data, known after the last reconcile
+func (s *GeneratedSecret) Expiration() *metav1.Time {
+	return s.expiration
+}
+
+// Deadline returns when the generated data need to be rotated, RotateBefore its earliest expiration
+func (s *GeneratedSecret) Deadline() *metav1.Time {
+	if s.RotateBefore <= 0 || s.expiration == nil {
+		return nil
+	}
+	t := metav1.NewTime(s.expiration.Add(-s.RotateBefore))
+	return &t
+}
+
+func (s *GeneratedSecret) Reconcile(ctx context.Context, cli client.Client, owner metav1.Object) (bool, error) {
+	namespace := owner.GetNamespace()
+	obj, err := s.Create()
+	if err != nil {
+		return false, err
+	}
+	desired := obj.(*v1.Secret).DeepCopy()
+
+	secret := &v1.Secret{}
+	create := false
+	if err := cli.Get(ctx, client.ObjectKey{Name: desired.Name, Namespace: namespace}, secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		create = true
+	}
+	if !create && strings.ToLower(secret.Annotations[types.UpdateKey]) == "disabled" {
+		return false, nil
+	}
+
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	changed, err := s.generate(namespace, data)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range desired.Data {
+		if !bytes.Equal(data[k], v) {
+			data[k] = v
+			changed = true
+		}
+	}
+	s.expiration = s.earliestExpiration(data)
+
+	if create {
+		desired.Namespace = namespace
+		desired.Data = data
+		if err := controllerutil.SetOwnerReference(owner, desired, cli.Scheme()); err != nil {
+			return false, err
+		}
+		return true, cli.Create(ctx, desired)
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		if secret.Labels[k] != v {
+			secret.Labels[k] = v
+			changed = true
+		}
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	for k, v := range desired.Annotations {
+		if secret.Annotations[k] != v {
+			secret.Annotations[k] = v
+			changed = true
+		}
+	}
+	if !changed || (secret.Immutable != nil && *secret.Immutable) {
+		return false, nil
+	}
+	secret.Data = data
+	return true, cli.Patch(ctx, secret, patch)
+}
+
+// generate the data of the generators with missing keys or expiring data, data is updated in place
+func (s *GeneratedSecret) generate(namespace string, data map[string][]byte) (bool, error) {
+	changed := false
+	now := time.Now()
+	for _, generator := range s.Generators {
+		required := false
+		for _, key := range generator.Keys() {
+			if _, ok := data[key]; !ok {
+				required = true
+				break
+			}
+		}
+		if !required && s.RotateBefore > 0 {
+			if expiration, ok := generator.(SecretExpiration); ok {
+				if t := expiration.Expiration(data); t == nil || t.Before(now.Add(s.RotateBefore)) {
+					required = true
+				}
+			}
+		}
+		if !required {
+			continue
+		}
+		values, err := generator.Generate(namespace, data)
+		if err != nil {
+			return false, err
+		}
+		for k, v := range values {
+			data[k] = v
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func (s *GeneratedSecret) earliestExpiration(data map[string][]byte) *metav1.Time {
+	var earliest *time.Time
+	for _, generator := range s.Generators {
+		if expiration, ok := generator.(SecretExpiration); ok {
+			if t := expiration.Expiration(data); t != nil && (earliest == nil || t.Before(*earliest)) {
+				earliest = t
+			}
+		}
+	}
+	if earliest == nil {
+		return nil
+	}
+	t := metav1.NewTime(*earliest)
+	return &t
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+)
+
+const (
+	CharsetLowercase    = "abcdefghijklmnopqrstuvwxyz"
+	CharsetUppercase    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	CharsetDigits       = "0123456789"
+	CharsetSymbols      = "!#$%&*+-.:=?@^_~"
+	CharsetAlphanumeric = CharsetLowercase + CharsetUppercase + CharsetDigits
+)
+
+// SecretGenerator generate the values of some keys of a Secret.
+// The values are only generated when one of the keys is missing, or when they need to be rotated.
+type SecretGenerator interface {
+	// Keys returns the keys of the Secret owned by the generator
+	Keys() []string
+	// Generate returns the new values of the keys, existing hold the current data of the Secret
+	Generate(namespace string, existing map[string][]byte) (map[string][]byte, error)
+}
+
+// SecretExpiration is implemented by the generators with data that expire (e.g. certificates)
+type SecretExpiration interface {
+	// Expiration returns when the data expire, nil if unknown
+	Expiration(data map[string][]byte) *time.Time
+}
+
+// PasswordGenerator generate a random password
+type PasswordGenerator struct {
+	Key string
+	// Length of the password, default to 32
+	Length int
+	// Charset the characters allowed in the password, default to CharsetAlphanumeric
+	Charset string
+	// RequiredCharsets at least one character of each of these charsets will be in the password
+	RequiredCharsets []string
+}
+
+func (g *PasswordGenerator) Keys() []string { return []string{g.Key} }
+
+func (g *PasswordGenerator) Generate(string, map[string][]byte) (map[string][]byte, error) {
+	length := g.Length
+	if length <= 0 {
+		length = 32
+	}
+	charset := g.Charset
+	if charset == "" {
+		charset = CharsetAlphanumeric
+	}
+	if len(g.RequiredCharsets) > length {
+		return nil, errors.New("password length is smaller than the number of required charsets")
+	}
+	password := make([]byte, length)
+	for i := range password {
+		c, err := randomChar(charset)
+		if err != nil {
+			return nil, err
+		}
+		password[i] = c
+	}
+	// Replace random positions with the required characters
+	positions, err := randomPermutation(length)
+	if err != nil {
+		return nil, err
+	}
+	for i, required := range g.RequiredCharsets {
+		c, err := randomChar(required)
+		if err != nil {
+			return nil, err
+		}
+		password[positions[i]] = c
+	}
+	return map[string][]byte{g.Key: password}, nil
+}
+
+// RSAKeyGenerator generate a RSA key pair encoded in PEM.
+// The private key is encoded in PKCS#1 and the public key in PKIX
+type RSAKeyGenerator struct {
+	PrivateKey string
+	PublicKey  string
+	// Bits size of the key, default to 4096
+	Bits int
+}
+
+func (g *RSAKeyGenerator) Keys() []string { return []string{g.PrivateKey, g.PublicKey} }
+
+func (g *RSAKeyGenerator) Generate(string, map[string][]byte) (map[string][]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaBits(g.Bits))
+	if err != nil {
+		return nil, err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		g.PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		g.PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}),
+	}, nil
+}
+
+// ECDSAKeyGenerator generate a ECDSA key pair encoded in PEM.
+// The private key is encoded in SEC 1 and the public key in PKIX
+type ECDSAKeyGenerator struct {
+	PrivateKey string
+	PublicKey  string
+	// Curve of the key, default to P-256
+	Curve elliptic.Curve
+}
+
+func (g *ECDSAKeyGenerator) Keys() []string { return []string{g.PrivateKey, g.PublicKey} }
+
+func (g *ECDSAKeyGenerator) Generate(string, map[string][]byte) (map[string][]byte, error) {
+	curve := g.Curve
+	if curve == nil {
+		curve = elliptic.P256()
+	}
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	private, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		g.PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: private}),
+		g.PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}),
+	}, nil
+}
+
+// SSHKeyGenerator generate a RSA key pair usable by OpenSSH.
+// The private key is encoded in PEM (PKCS#1) and the public key in the authorized_keys format
+type SSHKeyGenerator struct {
+	PrivateKey string
+	PublicKey  string
+	// Bits size of the key, default to 4096
+	Bits int
+	// Comment added at the end of the public key
+	Comment string
+}
+
+func (g *SSHKeyGenerator) Keys() []string { return []string{g.PrivateKey, g.PublicKey} }
+
+func (g *SSHKeyGenerator) Generate(string, map[string][]byte) (map[string][]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaBits(g.Bits))
+	if err != nil {
+		return nil, err
+	}
+	// Wire format of a ssh-rsa public key (RFC 4253 section 6.6)
+	var wire []byte
+	wire = appendSSHString(wire, []byte("ssh-rsa"))
+	wire = appendSSHString(wire, big.NewInt(int64(key.PublicKey.E)).Bytes())
+	wire = appendSSHString(wire, mpint(key.PublicKey.N))
+	public := "ssh-rsa " + base64.StdEncoding.EncodeToString(wire)
+	if g.Comment != "" {
+		public += " " + g.Comment
+	}
+	return map[string][]byte{
+		g.PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		g.PublicKey:  []byte(public + "\n"),
+	}, nil
+}
+
+func rsaBits(bits int) int {
+	if bits <= 0 {
+		return 4096
+	}
+	return bits
+}
+
+func appendSSHString(buffer, value []byte) []byte {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(value)))
+	return append(append(buffer, length...), value...)
+}
+
+// mpint encode a positive big integer, a leading zero is needed if the high bit is set
+func mpint(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func randomChar(charset string) (byte, error) {
+	if charset == "" {
+		return 0, errors.New("charset can not be empty")
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
+
+// randomPermutation returns a random permutation of [0, n)
+func randomPermutation(n int) ([]int, error) {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		perm[i], perm[j.Int64()] = perm[j.Int64()], perm[i]
+	}
+	return perm, nil
+}
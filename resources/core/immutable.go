@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// DefaultKeepGenerations is the number of versions kept by an immutable ConfigMap or Secret, including the current one
+const DefaultKeepGenerations = 3
+
+// ImmutableConfigMap is a ConfigMap created under the name <name>-<content hash> with Immutable set.
+// A change of the data create a new ConfigMap, the workloads of the formation are rewritten to reference it.
+// The previous versions are kept for rollback and garbage collected after KeepGenerations.
+type ImmutableConfigMap struct {
+	*immutable[*v1.ConfigMap]
+}
+
+func NewImmutableConfigMap(configMap *v1.ConfigMap) *ImmutableConfigMap {
+	return &ImmutableConfigMap{
+		immutable: &immutable[*v1.ConfigMap]{
+			SimpleResource:  common.NewSimpleResource("configmap", configMap),
+			KeepGenerations: DefaultKeepGenerations,
+			hash: func(obj *v1.ConfigMap) string {
+				hf := sha256.New()
+				for _, key := range utils.SortedKeys(obj.Data) {
+					hf.Write([]byte(key + "=" + obj.Data[key] + "\n"))
+				}
+				for _, key := range utils.SortedKeys(obj.BinaryData) {
+					hf.Write([]byte(key + "="))
+					hf.Write(obj.BinaryData[key])
+					hf.Write([]byte("\n"))
+				}
+				return hex.EncodeToString(hf.Sum(nil))
+			},
+			setImmutable: func(obj *v1.ConfigMap) { obj.Immutable = utils.ToPointer(true) },
+			newList:      func() client.ObjectList { return &v1.ConfigMapList{} },
+		},
+	}
+}
+
+// ImmutableSecret is a Secret created under the name <name>-<content hash> with Immutable set.
+// A change of the data create a new Secret, the workloads of the formation are rewritten to reference it.
+// The previous versions are kept for rollback and garbage collected after KeepGenerations.
+type ImmutableSecret struct {
+	*immutable[*v1.Secret]
+}
+
+func NewImmutableSecret(secret *v1.Secret) *ImmutableSecret {
+	if secret == nil {
+		secret = &v1.Secret{}
+	}
+	return &ImmutableSecret{
+		immutable: &immutable[*v1.Secret]{
+			SimpleResource:  common.NewSimpleResource("secret", secret),
+			KeepGenerations: DefaultKeepGenerations,
+			hash: func(obj *v1.Secret) string {
+				hf := sha256.New()
+				hf.Write([]byte(obj.Type + "\n"))
+				for _, key := range utils.SortedKeys(obj.Data) {
+					hf.Write([]byte(key + "="))
+					hf.Write(obj.Data[key])
+					hf.Write([]byte("\n"))
+				}
+				for _, key := range utils.SortedKeys(obj.StringData) {
+					hf.Write([]byte(key + "=" + obj.StringData[key] + "\n"))
+				}
+				return hex.EncodeToString(hf.Sum(nil))
+			},
+			setImmutable: func(obj *v1.Secret) { obj.Immutable = utils.ToPointer(true) },
+			newList:      func() client.ObjectList { return &v1.SecretList{} },
+		},
+	}
+}
+
+type immutable[T client.Object] struct {
+	*common.SimpleResource[T]
+	// KeepGenerations number of versions kept, including the current one
+	KeepGenerations int
+
+	hash         func(T) string
+	setImmutable func(T)
+	newList      func() client.ObjectList
+}
+
+// VersionedName returns the name of the current version, <name>-<first 10 characters of the content hash>
+func (i *immutable[T]) VersionedName() (string, error) {
+	obj, err := i.Create()
+	if err != nil {
+		return "", err
+	}
+	return i.Name() + "-" + i.hash(obj.(T))[:10], nil
+}
+
+func (i *immutable[T]) Reconcile(ctx context.Context, cli client.Client, owner metav1.Object) (bool, error) {
+	namespace := owner.GetNamespace()
+	versionedName, err := i.VersionedName()
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	current := i.Runtime()
+	if err := cli.Get(ctx, client.ObjectKey{Name: versionedName, Namespace: namespace}, current); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		obj := i.Obj.DeepCopyObject().(T)
+		obj.SetName(versionedName)
+		obj.SetNamespace(namespace)
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[types.ImmutableNameKey] = utils.HashLabelValue(i.Name())
+		obj.SetLabels(labels)
+		i.setImmutable(obj)
+		if err := controllerutil.SetOwnerReference(owner, obj, cli.Scheme()); err != nil {
+			return false, err
+		}
+		if err := cli.Create(ctx, obj); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, i.garbageCollect(ctx, cli, namespace, versionedName)
+}
+
+// garbageCollect delete the oldest versions, the current version is always kept
+func (i *immutable[T]) garbageCollect(ctx context.Context, cli client.Client, namespace, current string) error {
+	keep := i.KeepGenerations
+	if keep < 1 {
+		keep = 1
+	}
+	list := i.newList()
+	if err := cli.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{types.ImmutableNameKey: utils.HashLabelValue(i.Name())}); err != nil {
+		return err
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	var previous []client.Object
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok || obj.GetName() == current {
+			continue
+		}
+		previous = append(previous, obj)
+	}
+	if len(previous) < keep {
+		return nil
+	}
+	// Newest first
+	sort.Slice(previous, func(a, b int) bool {
+		return previous[a].GetCreationTimestamp().Time.After(previous[b].GetCreationTimestamp().Time)
+	})
+	for _, obj := range previous[keep-1:] {
+		if err := cli.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Debug().Str("name", obj.GetName()).Msg("previous immutable version deleted")
+	}
+	return nil
+}
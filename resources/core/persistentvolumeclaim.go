@@ -1,16 +1,133 @@
 package core
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/davidboxer/formation/resources/common"
+	"github.com/davidboxer/formation/types"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// PersistentVolumeClaim The spec of a claim is immutable once created, except for the storage request that can grow.
+// By default, the claim is retained when removed from the formation to avoid losing the data.
 type PersistentVolumeClaim struct {
 	*common.SimpleResource[*v1.PersistentVolumeClaim]
+	WaitForConverged bool
+	// Retain the claim is not deleted when removed from the formation, default to true
+	Retain bool
 }
 
 func NewPersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) *PersistentVolumeClaim {
 	return &PersistentVolumeClaim{
-		SimpleResource: common.NewSimpleResource("persistentvolumeclaim", pvc),
+		SimpleResource:   common.NewSimpleResource("persistentvolumeclaim", pvc),
+		WaitForConverged: true,
+		Retain:           true,
+	}
+}
+
+func (c *PersistentVolumeClaim) Create() (client.Object, error) {
+	if c.Obj.Annotations == nil {
+		c.Obj.Annotations = make(map[string]string)
+	}
+	if c.Retain {
+		c.Obj.Annotations[types.RetainKey] = "true"
+	} else {
+		delete(c.Obj.Annotations, types.RetainKey)
+	}
+	return c.SimpleResource.Create()
+}
+
+// Update only the metadata and the storage request, the rest of the spec is immutable.
+// Shrinking the storage request is not supported by Kubernetes and return an error.
+func (c *PersistentVolumeClaim) Update(ctx context.Context, fromApiServer runtime.Object) error {
+	pvc, ok := fromApiServer.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return types.ErrWrongResourceType
+	}
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{}
+	}
+	for k, v := range c.Obj.Labels {
+		pvc.Labels[k] = v
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	for k, v := range c.Obj.Annotations {
+		pvc.Annotations[k] = v
+	}
+	// The claim is no longer retained, the annotation set by a previous version must be removed
+	if !c.Retain {
+		delete(pvc.Annotations, types.RetainKey)
+	}
+
+	desired, ok := c.Obj.Spec.Resources.Requests[v1.ResourceStorage]
+	if !ok {
+		return nil
+	}
+	current := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	switch desired.Cmp(current) {
+	case -1:
+		return fmt.Errorf("persistentvolumeclaim %s can not shrink from %s to %s", pvc.Name, current.String(), desired.String())
+	case 1:
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = desired
+	}
+	return nil
+}
+
+// Converged The claim is ready once bound. A claim using a storage class with the WaitForFirstConsumer binding mode
+// is only bound once a pod use it, it is considered ready while pending.
+func (c *PersistentVolumeClaim) Converged(ctx context.Context, cli client.Client, namespace string) (bool, error) {
+	if !c.WaitForConverged {
+		return true, nil
+	}
+	pvc := &v1.PersistentVolumeClaim{}
+	if err := cli.Get(ctx, client.ObjectKey{Name: c.Obj.Name, Namespace: namespace}, pvc); err != nil {
+		return false, err
+	}
+	switch pvc.Status.Phase {
+	case v1.ClaimBound:
+		return true, nil
+	case v1.ClaimLost:
+		return false, fmt.Errorf("persistentvolumeclaim %s lost its volume", pvc.Name)
+	}
+	storageClass, err := c.storageClass(ctx, cli, pvc)
+	if err != nil || storageClass == nil {
+		return false, err
+	}
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer, nil
+}
+
+// storageClass returns the storage class of the claim, or the default storage class if none is set
+func (c *PersistentVolumeClaim) storageClass(ctx context.Context, cli client.Client, pvc *v1.PersistentVolumeClaim) (*storagev1.StorageClass, error) {
+	if pvc.Spec.StorageClassName != nil {
+		if *pvc.Spec.StorageClassName == "" {
+			return nil, nil
+		}
+		storageClass := &storagev1.StorageClass{}
+		if err := cli.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, storageClass); err != nil {
+			return nil, err
+		}
+		return storageClass, nil
+	}
+	list := &storagev1.StorageClassList{}
+	if err := cli.List(ctx, list); err != nil {
+		return nil, err
+	}
+	for idx, storageClass := range list.Items {
+		if strings.ToLower(storageClass.Annotations[defaultStorageClassAnnotation]) == "true" {
+			return &list.Items[idx], nil
+		}
 	}
+	return nil, nil
 }
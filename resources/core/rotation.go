@@ -0,0 +1,302 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/davidboxer/formation/types"
+	"github.com/davidboxer/formation/utils"
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const rotationStateOverlap = "overlap"
+
+// overlapCheckInterval is the delay between two checks of the pods during the overlap
+const overlapCheckInterval = 10 * time.Second
+
+// RotationPolicy define when a RotatingSecret is rotated
+type RotationPolicy struct {
+	// Interval between two rotations, 0 disable the time based rotation. The owner is requeued at the next rotation.
+	// A rotation can always be triggered by setting the types.RotateKey annotation on the Secret
+	Interval time.Duration
+	// PreviousSuffix is appended to the keys holding the previous values during the overlap, default to ".previous"
+	PreviousSuffix string
+}
+
+// RotatingSecret is a GeneratedSecret that is rotated in stages:
+//  1. New values are generated, the previous values are kept under <key><PreviousSuffix>
+//  2. The workloads (Deployment, StatefulSet, DaemonSet) of the formation referencing the Secret are rolled
+//  3. Once all the pods of those workloads run with the new version, the previous values are dropped
+//
+// Only one rotation is in progress at a time.
+type RotatingSecret struct {
+	*GeneratedSecret
+	Policy RotationPolicy
+
+	// next is the next rotation or check of the overlap, known after the last reconcile
+	next *metav1.Time
+}
+
+func NewRotatingSecret(secret *v1.Secret, policy RotationPolicy, generators ...SecretGenerator) *RotatingSecret {
+	return &RotatingSecret{
+		GeneratedSecret: NewGeneratedSecret(secret, generators...),
+		Policy:          policy,
+	}
+}
+
+// Deadline returns the earliest of the rotation of the expiring data, the next rotation of the interval
+// and the next check of the pods during the overlap
+func (s *RotatingSecret) Deadline() *metav1.Time {
+	deadline := s.GeneratedSecret.Deadline()
+	if s.next != nil && (deadline == nil || s.next.Before(deadline)) {
+		deadline = s.next
+	}
+	return deadline
+}
+
+func (s *RotatingSecret) Reconcile(ctx context.Context, cli client.Client, owner metav1.Object) (bool, error) {
+	s.next = nil
+	// Create the Secret and generate the missing keys first, the rotation start on the next reconcile
+	changed, err := s.GeneratedSecret.Reconcile(ctx, cli, owner)
+	if err != nil || changed {
+		s.setNext(time.Now())
+		return changed, err
+	}
+	namespace := owner.GetNamespace()
+	secret := &v1.Secret{}
+	if err := cli.Get(ctx, client.ObjectKey{Name: s.Obj.Name, Namespace: namespace}, secret); err != nil {
+		return false, err
+	}
+	if secret.Immutable != nil && *secret.Immutable {
+		return false, nil
+	}
+	if secret.Annotations[types.RotationStateKey] == rotationStateOverlap {
+		completed, err := s.completeRotation(ctx, cli, owner, secret)
+		if err != nil {
+			return false, err
+		}
+		if completed {
+			s.setNextRotation(secret)
+		} else {
+			s.setNext(time.Now().Add(overlapCheckInterval))
+		}
+		return completed, nil
+	}
+	if !s.needRotation(secret) {
+		s.setNextRotation(secret)
+		return false, nil
+	}
+	if err := s.rotate(ctx, cli, owner, secret); err != nil {
+		return false, err
+	}
+	s.setNext(time.Now().Add(overlapCheckInterval))
+	return true, nil
+}
+
+// needRotation returns true if the rotation was triggered by the annotation or the interval elapsed
+func (s *RotatingSecret) needRotation(secret *v1.Secret) bool {
+	if trigger, ok := secret.Annotations[types.RotateKey]; ok && trigger != secret.Annotations[types.RotationTriggerKey] {
+		return true
+	}
+	if s.Policy.Interval <= 0 {
+		return false
+	}
+	return time.Now().After(rotatedAt(secret).Add(s.Policy.Interval))
+}
+
+// setNextRotation set the next deadline to the next time based rotation, if enabled
+func (s *RotatingSecret) setNextRotation(secret *v1.Secret) {
+	if s.Policy.Interval > 0 {
+		s.setNext(rotatedAt(secret).Add(s.Policy.Interval))
+	}
+}
+
+func (s *RotatingSecret) setNext(t time.Time) {
+	next := metav1.NewTime(t)
+	s.next = &next
+}
+
+// rotatedAt returns the time of the last rotation, the creation of the Secret if it was never rotated
+func rotatedAt(secret *v1.Secret) time.Time {
+	t, err := time.Parse(time.RFC3339, secret.Annotations[types.RotatedAtKey])
+	if err != nil {
+		return secret.CreationTimestamp.Time
+	}
+	return t
+}
+
+// rotate generate the new values, keep the previous one and roll the workloads
+func (s *RotatingSecret) rotate(ctx context.Context, cli client.Client, owner metav1.Object, secret *v1.Secret) error {
+	patch := client.MergeFrom(secret.DeepCopy())
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	for _, generator := range s.Generators {
+		for _, key := range generator.Keys() {
+			if value, ok := secret.Data[key]; ok {
+				data[key+s.previousSuffix()] = value
+			}
+		}
+		values, err := generator.Generate(secret.Namespace, data)
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			data[k] = v
+		}
+	}
+	version, _ := strconv.Atoi(secret.Annotations[types.RotationVersionKey])
+	version++
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[types.RotationVersionKey] = strconv.Itoa(version)
+	secret.Annotations[types.RotationStateKey] = rotationStateOverlap
+	secret.Annotations[types.RotatedAtKey] = time.Now().UTC().Format(time.RFC3339)
+	if trigger, ok := secret.Annotations[types.RotateKey]; ok {
+		secret.Annotations[types.RotationTriggerKey] = trigger
+	}
+	secret.Data = data
+	s.expiration = s.earliestExpiration(data)
+	if err := cli.Patch(ctx, secret, patch); err != nil {
+		return err
+	}
+	log.Info().Str("secret", secret.Name).Int("version", version).Msg("secret rotated")
+	_, err := s.rollWorkloads(ctx, cli, owner, secret.Name, strconv.Itoa(version))
+	return err
+}
+
+// rollWorkloads set the version of the Secret on the pod template of the workloads of the formation referencing it.
+// The selectors of those workloads are returned, the workloads not controlled by the owner are never changed
+func (s *RotatingSecret) rollWorkloads(ctx context.Context, cli client.Client, owner metav1.Object, name, version string) ([]*metav1.LabelSelector, error) {
+	key := RotationPodAnnotation(name)
+	var selectors []*metav1.LabelSelector
+	stamp := func(obj client.Object, template *v1.PodTemplateSpec, selector *metav1.LabelSelector) error {
+		if !metav1.IsControlledBy(obj, owner) || !referenceSecret(&template.Spec, name) {
+			return nil
+		}
+		selectors = append(selectors, selector)
+		if template.Annotations[key] == version {
+			return nil
+		}
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[key] = version
+		return cli.Patch(ctx, obj, patch)
+	}
+
+	namespace := owner.GetNamespace()
+	deployments := &appsv1.DeploymentList{}
+	if err := cli.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for idx := range deployments.Items {
+		item := &deployments.Items[idx]
+		if err := stamp(item, &item.Spec.Template, item.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := cli.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for idx := range statefulSets.Items {
+		item := &statefulSets.Items[idx]
+		if err := stamp(item, &item.Spec.Template, item.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := cli.List(ctx, daemonSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for idx := range daemonSets.Items {
+		item := &daemonSets.Items[idx]
+		if err := stamp(item, &item.Spec.Template, item.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+	return selectors, nil
+}
+
+// completeRotation drop the previous values once all the pods of the rolled workloads run with the current version
+func (s *RotatingSecret) completeRotation(ctx context.Context, cli client.Client, owner metav1.Object, secret *v1.Secret) (bool, error) {
+	version := secret.Annotations[types.RotationVersionKey]
+	// Workloads created or changed during the overlap need to be rolled as well
+	selectors, err := s.rollWorkloads(ctx, cli, owner, secret.Name, version)
+	if err != nil {
+		return false, err
+	}
+	key := RotationPodAnnotation(secret.Name)
+	for _, selector := range selectors {
+		if selector == nil {
+			continue
+		}
+		podSelector, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return false, err
+		}
+		pods := &v1.PodList{}
+		if err := cli.List(ctx, pods, client.InNamespace(secret.Namespace), client.MatchingLabelsSelector{Selector: podSelector}); err != nil {
+			return false, err
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			if referenceSecret(&pod.Spec, secret.Name) && pod.Annotations[key] != version {
+				// Some pods still use the previous values
+				return false, nil
+			}
+		}
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	for _, generator := range s.Generators {
+		for _, key := range generator.Keys() {
+			delete(secret.Data, key+s.previousSuffix())
+		}
+	}
+	delete(secret.Annotations, types.RotationStateKey)
+	log.Info().Str("secret", secret.Name).Str("version", version).Msg("secret rotation completed")
+	return true, cli.Patch(ctx, secret, patch)
+}
+
+func (s *RotatingSecret) previousSuffix() string {
+	if s.Policy.PreviousSuffix == "" {
+		return ".previous"
+	}
+	return s.Policy.PreviousSuffix
+}
+
+// RotationPodAnnotation returns the pod template annotation holding the version of a rotating Secret.
+// Long Secret names are hashed to respect the 63 characters limit of the annotation name
+func RotationPodAnnotation(secretName string) string {
+	name := secretName
+	if len(name) > 63 {
+		hf := fnv.New32()
+		_, _ = hf.Write([]byte(secretName))
+		name = fmt.Sprintf("%s-%d", secretName[:52], hf.Sum32())
+	}
+	return "rotation.formation/" + name
+}
+
+func referenceSecret(spec *v1.PodSpec, name string) bool {
+	_, secrets := utils.PodSpecReferences(spec)
+	for _, secret := range secrets {
+		if secret == name {
+			return true
+		}
+	}
+	return false
+}
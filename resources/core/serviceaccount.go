@@ -0,0 +1,16 @@
+package core
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	v1 "k8s.io/api/core/v1"
+)
+
+type ServiceAccount struct {
+	*common.SimpleResource[*v1.ServiceAccount]
+}
+
+func NewServiceAccount(serviceAccount *v1.ServiceAccount) *ServiceAccount {
+	return &ServiceAccount{
+		SimpleResource: common.NewSimpleResource("serviceaccount", serviceAccount),
+	}
+}
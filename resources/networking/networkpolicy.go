@@ -0,0 +1,16 @@
+package networking
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	v1 "k8s.io/api/networking/v1"
+)
+
+type NetworkPolicy struct {
+	*common.SimpleResource[*v1.NetworkPolicy]
+}
+
+func NewNetworkPolicy(networkPolicy *v1.NetworkPolicy) *NetworkPolicy {
+	return &NetworkPolicy{
+		SimpleResource: common.NewSimpleResource("networkpolicy", networkPolicy),
+	}
+}
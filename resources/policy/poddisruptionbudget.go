@@ -0,0 +1,16 @@
+package policy
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	v1 "k8s.io/api/policy/v1"
+)
+
+type PodDisruptionBudget struct {
+	*common.SimpleResource[*v1.PodDisruptionBudget]
+}
+
+func NewPodDisruptionBudget(pdb *v1.PodDisruptionBudget) *PodDisruptionBudget {
+	return &PodDisruptionBudget{
+		SimpleResource: common.NewSimpleResource("poddisruptionbudget", pdb),
+	}
+}
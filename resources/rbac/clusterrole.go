@@ -0,0 +1,34 @@
+package rbac
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ClusterRole is not namespaced, the controller will not set the namespace nor the owner reference on it.
+// It will only be deleted when removed from the formation list.
+type ClusterRole struct {
+	*common.SimpleResource[*rbacv1.ClusterRole]
+}
+
+func NewClusterRole(clusterRole *rbacv1.ClusterRole) *ClusterRole {
+	return &ClusterRole{
+		SimpleResource: common.NewSimpleResource("clusterrole", clusterRole),
+	}
+}
+
+func (c *ClusterRole) ClusterScoped() bool { return true }
+
+// ClusterRoleBinding is not namespaced, the controller will not set the namespace nor the owner reference on it.
+// It will only be deleted when removed from the formation list.
+type ClusterRoleBinding struct {
+	*common.SimpleResource[*rbacv1.ClusterRoleBinding]
+}
+
+func NewClusterRoleBinding(clusterRoleBinding *rbacv1.ClusterRoleBinding) *ClusterRoleBinding {
+	return &ClusterRoleBinding{
+		SimpleResource: common.NewSimpleResource("clusterrolebinding", clusterRoleBinding),
+	}
+}
+
+func (c *ClusterRoleBinding) ClusterScoped() bool { return true }
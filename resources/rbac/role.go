@@ -0,0 +1,26 @@
+package rbac
+
+import (
+	"github.com/davidboxer/formation/resources/common"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type Role struct {
+	*common.SimpleResource[*rbacv1.Role]
+}
+
+func NewRole(role *rbacv1.Role) *Role {
+	return &Role{
+		SimpleResource: common.NewSimpleResource("role", role),
+	}
+}
+
+type RoleBinding struct {
+	*common.SimpleResource[*rbacv1.RoleBinding]
+}
+
+func NewRoleBinding(roleBinding *rbacv1.RoleBinding) *RoleBinding {
+	return &RoleBinding{
+		SimpleResource: common.NewSimpleResource("rolebinding", roleBinding),
+	}
+}
@@ -37,8 +37,9 @@ type AddEnvFromSourceToContainer interface {
 }
 
 // AddTemplateVolumeToContainer is the interface that adds a Volume Template to the builder and which container it belongs to
+// Only the builders accepting VolumeClaimTemplates (e.g. StatefulSet) implement it
 type AddTemplateVolumeToContainer interface {
-	AddVolumeToContainer(containerName string, containerVolume v1.VolumeMount, template v1.PersistentVolumeClaimSpec)
+	AddTemplateVolumeToContainer(containerName string, containerVolume v1.VolumeMount, template v1.PersistentVolumeClaimSpec)
 }
 
 // ResourcesName is the interface that returns the name of the resources that the builder creates
@@ -48,6 +49,16 @@ type ResourcesName interface {
 	ResourcesName() []string
 }
 
+// ContainerTypes is the interface that returns the type of each container of the pods created by the builder, by container name
+type ContainerTypes interface {
+	ContainerTypes() map[string]ContainerType
+}
+
+// PodLabels is the interface that returns the labels of the pods created by the builder
+type PodLabels interface {
+	PodLabels() map[string]string
+}
+
 // Depending on how the controller is implemented, the following interfaces might be useful.
 // An Example, if the controller is watching an CR that allow user to customize the resource,
 // the following interfaces will be useful for all builder types to implement.
@@ -57,6 +68,13 @@ type ConfigurableContainer interface {
 	// AddResourceRequirements Add resource requirements to the container
 	AddResourceRequirements(containerName string, requirements v1.ResourceRequirements)
 
+	// MergeResourceRequirements Merge the resource requirements into the ones of the container, only the resources set are replaced
+	MergeResourceRequirements(containerName string, requirements v1.ResourceRequirements)
+
+	// SetResourceSizing Set the resources of the container from the tier of the sizing, then merge its overrides.
+	// An error is returned if the container does not exist or the tier is unknown
+	SetResourceSizing(containerName string, tiers SizingTiers, sizing ResourceSizing) error
+
 	//AddEnv Add environment variables to the container
 	AddEnv(containerName string, env ...v1.EnvVar)
 
@@ -74,6 +92,12 @@ type ConfigurableContainer interface {
 	SetLivenessProbeConfiguration(containerName string, probe ProbeConfiguration)
 }
 
+// SizingTiers is the interface that returns the resources of a container in a sizing tier, e.g. apps.Sizing.
+// The second value is false if the tier has no entry for the container
+type SizingTiers interface {
+	Requirements(tier SizingTier, container *v1.Container) (v1.ResourceRequirements, bool, error)
+}
+
 // ConfigurablePod is the interface that allows the user to customize the Pod
 type ConfigurablePod interface {
 	// AddAffinity Add affinity to the pod
@@ -121,8 +145,65 @@ type Update interface {
 	Update(ctx context.Context, fromApiServer runtime.Object) error
 }
 
+// Versioned If the resource is created under a versioned name (e.g. an immutable ConfigMap with a content hash suffix),
+// it can implement this interface. The build-in controller rewrite the references of the pod templates
+// in the formation from the Name to the VersionedName.
+// Optional
+type Versioned interface {
+	VersionedName() (string, error)
+}
+
+// Expiration If the resource data expire (e.g. a certificate), it can implement this interface.
+// The build-in controller report the expiration in the resource status.
+// Optional
+type Expiration interface {
+	Expiration() *v11.Time
+}
+
+// Deadline If the resource need to be reconciled at a given time (e.g. to rotate its data before it expires),
+// it can implement this interface. The build-in controller requeue the owner at the earliest deadline of the formation.
+// Optional
+type Deadline interface {
+	// Deadline returns the next time the resource need to be reconciled, known after the last reconcile. Nil if none
+	Deadline() *v11.Time
+}
+
+// ExternalReferences If the pods of the resource reference objects that are not part of the formation
+// (e.g. an existing PersistentVolumeClaim), it can implement this interface.
+// The validation of the formation does not report them as dangling.
+// Optional
+type ExternalReferences interface {
+	// ExternalReferences returns the <type>/<name> of the objects, e.g. persistentvolumeclaim/data
+	ExternalReferences() []string
+}
+
+// SharedObject If the resource is built from an object it keeps (e.g. common.SimpleResource), it can implement this interface.
+// The changes made to the object are returned by the next Create, Create itself may return a copy.
+// Optional
+type SharedObject interface {
+	Object() client.Object
+}
+
+// ClusterScoped If the resource is not namespaced (e.g. ClusterRole), it need to implement this interface.
+// The build-in controller will not set the namespace nor the owner reference on the object,
+// a namespaced owner can not own a cluster scoped resource.
+// Optional
+type ClusterScoped interface {
+	ClusterScoped() bool
+}
+
 // Reconcile If the resource need to implement their own reconcile logic, they can implement this interface
 // Optional
 type Reconcile interface {
 	Reconcile(ctx context.Context, client client.Client, owner v11.Object) (bool, error)
 }
+
+// Policy is run by the build-in controller on every object created by the formation, before it is created or patched.
+// A policy can mutate the object (e.g. inject labels) or reject it by returning an error,
+// the reason is reported in the resource status and the object is not applied.
+// The resources implementing Reconcile apply their objects themselves and are not checked.
+type Policy interface {
+	// Name of the policy, used in the rejection reason
+	Name() string
+	Apply(ctx context.Context, obj client.Object) error
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 var (
@@ -17,6 +18,41 @@ const (
 	//UpdateKey if is set to "disabled", the resource will not be updated.
 	//Once this is set on a resource, it will not be updated unless the annotation is removed.
 	UpdateKey = "formation/update"
+
+	//ConfigChecksumKey contain the checksum of the ConfigMaps and Secrets referenced by a pod template.
+	//It is set on the pod template annotations when the controller config checksum is enabled.
+	ConfigChecksumKey = "formation/config-checksum"
+
+	//RotateKey set this annotation on a rotating Secret to a new value to trigger a rotation.
+	RotateKey = "formation/rotate"
+	//RotationVersionKey contain the version of a rotating Secret, incremented on every rotation.
+	//The version is also set on the pod template of the workloads referencing the Secret.
+	RotationVersionKey = "formation/rotation-version"
+	//RotationStateKey is set to "overlap" while the previous values of a rotating Secret are still available.
+	RotationStateKey = "formation/rotation-state"
+	//RotatedAtKey contain the time of the last rotation of a rotating Secret, in RFC 3339.
+	RotatedAtKey = "formation/rotated-at"
+	//RotationTriggerKey contain the last value of RotateKey handled by the rotation.
+	RotationTriggerKey = "formation/rotation-trigger"
+
+	//ImmutableNameKey contain the hash (see utils.HashLabelValue) of the name of an immutable ConfigMap or Secret
+	//without its content hash suffix, the names are longer than the 63 characters of a label value.
+	//It is used to find the previous versions to garbage collect.
+	ImmutableNameKey = "formation/immutable-name"
+
+	//RetainKey if is set to "true", the resource is not deleted when it is removed from the formation.
+	RetainKey = "formation/retain"
+)
+
+// ContainerType is the role of a container in a pod
+type ContainerType string
+
+const (
+	ContainerTypeRegular ContainerType = "regular"
+	ContainerTypeInit    ContainerType = "init"
+	// ContainerTypeSidecar is a container supporting the main containers of the pod (e.g. log shipper): a native sidecar
+	// (an init container with the restartPolicy Always) or a regular container marked as sidecar
+	ContainerTypeSidecar ContainerType = "sidecar"
 )
 
 type ResourceState string
@@ -49,11 +85,94 @@ const (
 	StorageConfigTypeCreate StorageConfigType = "create"
 )
 
+// SecurityProfile is a Pod Security Standards level
+// +kubebuilder:validation:Enum=privileged;baseline;restricted
+type SecurityProfile string
+
+const (
+	// SecurityProfilePrivileged is unrestricted
+	SecurityProfilePrivileged SecurityProfile = "privileged"
+	// SecurityProfileBaseline prevents the known privilege escalations
+	SecurityProfileBaseline SecurityProfile = "baseline"
+	// SecurityProfileRestricted follows the pod hardening best practices
+	SecurityProfileRestricted SecurityProfile = "restricted"
+)
+
+// SecurityIDs are the user and group ids of a pod, the ids left unset are assigned by the platform
+// (e.g. the OpenShift restricted-v2 SCC assigns them from the namespace range)
+type SecurityIDs struct {
+	RunAsUser  *int64 `json:"runAsUser,omitempty"`
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+	FSGroup    *int64 `json:"fsGroup,omitempty"`
+}
+
+// SizingTier is the name of a resource sizing tier, e.g. small, medium or large
+type SizingTier string
+
+const (
+	SizingTierSmall  SizingTier = "small"
+	SizingTierMedium SizingTier = "medium"
+	SizingTierLarge  SizingTier = "large"
+)
+
+// ResourceSizing is the sizing of a workload, as exposed in a CR
+type ResourceSizing struct {
+	// Size is the tier of the workload, the resources of each container are looked up in the tier
+	// +optional
+	Size SizingTier `json:"size,omitempty" yaml:"size,omitempty"`
+	// Resources override the requests and limits of the tier, only the resources set are overridden
+	// +optional
+	Resources *v1.ResourceRequirements `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+func (in *ResourceSizing) DeepCopyInto(t *ResourceSizing) {
+	*t = *in
+	t.Resources = in.Resources.DeepCopy()
+}
+
+func (in *ResourceSizing) DeepCopy() *ResourceSizing {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceSizing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// PatchType is the format of a Patch
+// +kubebuilder:validation:Enum=strategic;merge;json6902
+type PatchType string
+
+const (
+	// PatchTypeStrategicMerge is a Kubernetes strategic merge patch, a JSON merge patch for the unstructured objects
+	PatchTypeStrategicMerge PatchType = "strategic"
+	// PatchTypeMerge is a JSON merge patch (RFC 7386)
+	PatchTypeMerge PatchType = "merge"
+	// PatchTypeJSON6902 is a list of JSON patch operations (RFC 6902)
+	PatchTypeJSON6902 PatchType = "json6902"
+)
+
+// Patch is a change applied to an object of the formation
+type Patch struct {
+	// Target is <type>/<name> of the resource, e.g. deployment/api. The type and the name can be globs, e.g. */api-*
+	Target string `json:"target" yaml:"target"`
+	// Type of the patch, if empty a list is a json6902 patch and an object a strategic merge patch
+	// +optional
+	Type PatchType `json:"type,omitempty" yaml:"type,omitempty"`
+	// Patch in YAML or JSON
+	Patch string `json:"patch" yaml:"patch"`
+}
+
 type LinkVolumeData struct {
-	//The name of the container that this volume is mounted to
-	// Format is <PodName>.<ContainerName>
-	// Wildcard is supported, e.g. <PodName>.* will mount to all containers in the pod
-	// Regex is not supported
+	//The containers that this volume is mounted to
+	// Format is [!]<Pod>[/<Container>][@<ContainerType>]
+	//  - Pod is a name, a glob (api-*), a regex prefixed with ~ (~api-[0-9]+) or a label selector in brackets ([app=api,tier!=db])
+	//    A name also match the pods prefixed with an instance name, e.g. api match my-instance-api
+	//  - Container is a name, a glob or a regex prefixed with ~, all the containers if omitted. A regex can not contain /
+	//  - ContainerType is init, regular, sidecar or all, separated by +. Default to regular+sidecar.
+	//    The native sidecars (init containers with the restartPolicy Always) are sidecar, not init
+	//  - An entry prefixed with ! exclude the matching containers
+	// Unparsable entries are reported by ValidateLinkVolumes
 	Visibility []string `json:"visibility,omitempty" yaml:"visibility"`
 
 	VolumeMount v1.VolumeMount `json:"volumeMount,omitempty" yaml:"volumeMount"`
@@ -70,11 +189,137 @@ type LinkVolumeData struct {
 	// sources, the value associated with the last source will take precedence.
 	// Values defined by an Env with a duplicate key will take precedence.
 	EnvFromSource *v1.EnvFromSource `json:"envFromSource,omitempty" yaml:"envFromSource"`
+
+	// ExternalSecret If set, the secret is copied from outside the formation into the owner namespace.
+	// The VolumeSource or EnvFromSource is pointed to the copy, if none is set the secret is mounted with VolumeMount.
+	ExternalSecret *ExternalSecretReference `json:"externalSecret,omitempty" yaml:"externalSecret"`
+}
+
+// ExternalSecretReference reference a secret living outside the formation
+type ExternalSecretReference struct {
+	// Source is the name of the SecretSource providing the secret, default to "kubernetes". The sources are registered by the operator
+	// +optional
+	Source string `json:"source,omitempty" yaml:"source"`
+	// Namespace of the secret, required by the "kubernetes" source
+	// +optional
+	Namespace string `json:"namespace,omitempty" yaml:"namespace"`
+	// Name of the secret in the source
+	Name string `json:"name" yaml:"name"`
+	// Keys to copy, all the keys are copied if empty
+	// +optional
+	Keys []string `json:"keys,omitempty" yaml:"keys"`
+	// LocalName is the name of the copy in the owner namespace, default to <namespace>-<name> or <source>-<name>
+	// +optional
+	LocalName string `json:"localName,omitempty" yaml:"localName"`
+}
+
+func (in *LinkVolumeData) DeepCopyInto(t *LinkVolumeData) {
+	*t = *in
+	if in.Visibility != nil {
+		t.Visibility = make([]string, len(in.Visibility))
+		copy(t.Visibility, in.Visibility)
+	}
+	in.VolumeMount.DeepCopyInto(&t.VolumeMount)
+	t.VolumeSource = in.VolumeSource.DeepCopy()
+	t.Template = in.Template.DeepCopy()
+	t.EnvFromSource = in.EnvFromSource.DeepCopy()
+	t.ExternalSecret = in.ExternalSecret.DeepCopy()
+}
+
+func (in *LinkVolumeData) DeepCopy() *LinkVolumeData {
+	if in == nil {
+		return nil
+	}
+	out := new(LinkVolumeData)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *ExternalSecretReference) DeepCopyInto(t *ExternalSecretReference) {
+	*t = *in
+	if in.Keys != nil {
+		t.Keys = make([]string, len(in.Keys))
+		copy(t.Keys, in.Keys)
+	}
+}
+
+func (in *ExternalSecretReference) DeepCopy() *ExternalSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// StorageConfig describe a volume used by the workloads of the formation, see StorageConfigType for the behavior of each type
+type StorageConfig struct {
+	// Name of the volume, also the name of the claim for the create type
+	Name string `json:"name" yaml:"name"`
+	// Type of the storage, template, existing or create
+	Type StorageConfigType `json:"type,omitempty" yaml:"type"`
+	// Spec of the claim, required for the template and create types
+	// +optional
+	Spec *v1.PersistentVolumeClaimSpec `json:"spec,omitempty" yaml:"spec"`
+	// ClaimName of the existing claim, default to Name
+	// +optional
+	ClaimName string `json:"claimName,omitempty" yaml:"claimName"`
+	// MountPath where the volume is mounted in the containers
+	MountPath string `json:"mountPath" yaml:"mountPath"`
+	// +optional
+	SubPath string `json:"subPath,omitempty" yaml:"subPath"`
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty" yaml:"readOnly"`
+	// Visibility the containers mounting the volume, same format as LinkVolumeData
+	Visibility []string `json:"visibility,omitempty" yaml:"visibility"`
+}
+
+func (in *StorageConfig) DeepCopyInto(t *StorageConfig) {
+	*t = *in
+	t.Spec = in.Spec.DeepCopy()
+	if in.Visibility != nil {
+		t.Visibility = make([]string, len(in.Visibility))
+		copy(t.Visibility, in.Visibility)
+	}
+}
+
+func (in *StorageConfig) DeepCopy() *StorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *SecurityIDs) DeepCopyInto(t *SecurityIDs) {
+	*t = *in
+	if in.RunAsUser != nil {
+		t.RunAsUser = new(int64)
+		*t.RunAsUser = *in.RunAsUser
+	}
+	if in.RunAsGroup != nil {
+		t.RunAsGroup = new(int64)
+		*t.RunAsGroup = *in.RunAsGroup
+	}
+	if in.FSGroup != nil {
+		t.FSGroup = new(int64)
+		*t.FSGroup = *in.FSGroup
+	}
+}
+
+func (in *SecurityIDs) DeepCopy() *SecurityIDs {
+	if in == nil {
+		return nil
+	}
+	out := new(SecurityIDs)
+	in.DeepCopyInto(out)
+	return out
 }
 
 type ProbeConfiguration struct {
 	// Enable specifies whether the probe is enabled.
-	// The default value is true.
+	// The default value is true. A configuration left to its zero value keeps the existing probe
 	// +kubebuilder:default=true
 	// +optional
 	Enable bool `json:"enable" yaml:"enable"`
@@ -95,8 +340,42 @@ type ProbeConfiguration struct {
 	// Minimum consecutive failures for the probe to be considered failed after having succeeded.
 	// +optional
 	FailureThreshold *int32 `json:"failureThreshold,omitempty" protobuf:"varint,6,opt,name=failureThreshold"`
+
+	// Handler of the probe, if set the handler of the existing probe is replaced, or the probe is created.
+	// If empty, only the timing of an existing probe is configured
+	// +optional
+	Handler ProbeHandlerType `json:"handler,omitempty" yaml:"handler,omitempty"`
+	// Path to access on the HTTP server, for the httpGet handler
+	// +optional
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Port number or name of the container, for the httpGet, tcpSocket and grpc handlers. The grpc handler require a number
+	// +optional
+	Port *intstr.IntOrString `json:"port,omitempty" yaml:"port,omitempty"`
+	// Scheme to use for the httpGet handler, default to HTTP
+	// +optional
+	Scheme v1.URIScheme `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	// Headers to set in the request of the httpGet handler
+	// +optional
+	Headers []v1.HTTPHeader `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Command to execute for the exec handler
+	// +optional
+	Command []string `json:"command,omitempty" yaml:"command,omitempty"`
+	// Service name of the gRPC health check, for the grpc handler
+	// +optional
+	Service *string `json:"service,omitempty" yaml:"service,omitempty"`
 }
 
+// ProbeHandlerType is the action taken by a probe
+// +kubebuilder:validation:Enum=httpGet;tcpSocket;grpc;exec
+type ProbeHandlerType string
+
+const (
+	ProbeHandlerHTTPGet   ProbeHandlerType = "httpGet"
+	ProbeHandlerTCPSocket ProbeHandlerType = "tcpSocket"
+	ProbeHandlerGRPC      ProbeHandlerType = "grpc"
+	ProbeHandlerExec      ProbeHandlerType = "exec"
+)
+
 func (in *ProbeConfiguration) DeepCopyInto(t *ProbeConfiguration) {
 	t.Enable = in.Enable
 	if in.InitialDelaySeconds != nil {
@@ -119,6 +398,25 @@ func (in *ProbeConfiguration) DeepCopyInto(t *ProbeConfiguration) {
 		t.FailureThreshold = new(int32)
 		*t.FailureThreshold = *in.FailureThreshold
 	}
+	t.Handler = in.Handler
+	t.Path = in.Path
+	if in.Port != nil {
+		t.Port = new(intstr.IntOrString)
+		*t.Port = *in.Port
+	}
+	t.Scheme = in.Scheme
+	if in.Headers != nil {
+		t.Headers = make([]v1.HTTPHeader, len(in.Headers))
+		copy(t.Headers, in.Headers)
+	}
+	if in.Command != nil {
+		t.Command = make([]string, len(in.Command))
+		copy(t.Command, in.Command)
+	}
+	if in.Service != nil {
+		t.Service = new(string)
+		*t.Service = *in.Service
+	}
 }
 
 func (in *ProbeConfiguration) DeepCopy() *ProbeConfiguration {
@@ -133,6 +431,11 @@ func (in *ProbeConfiguration) DeepCopy() *ProbeConfiguration {
 type FormationStatusInterface interface {
 	GetStatus() *FormationStatus
 }
+
+// FormationPatchesInterface is implemented by the owner CR carrying patches for the objects of the formation
+type FormationPatchesInterface interface {
+	GetPatches() []Patch
+}
 type ResourceStatus struct {
 	Name  string        `json:"name,omitempty"`
 	Type  string        `json:"type,omitempty"`
@@ -141,6 +444,12 @@ type ResourceStatus struct {
 	// +kubebuilder:validation:Type=string
 	// +kubebuilder:validation:Format="date-time"
 	LastUpdate metav1.Time `json:"lastUpdate,omitempty"`
+	// Message explain why the resource is not reconciled, empty when the last reconcile succeeded
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Expiration of the resource data (e.g. a certificate), if the resource data expire
+	// +optional
+	Expiration *metav1.Time `json:"expiration,omitempty"`
 }
 
 type FormationStatus struct {
@@ -154,7 +463,7 @@ func (in *FormationStatus) DeepCopyInto(t *FormationStatus) {
 			continue
 		}
 		t.Resources = append(t.Resources,
-			&ResourceStatus{Name: res.Name, Group: res.Group, Type: res.Type, State: res.State})
+			&ResourceStatus{Name: res.Name, Group: res.Group, Type: res.Type, State: res.State, Message: res.Message, Expiration: res.Expiration.DeepCopy()})
 	}
 }
 
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistry is the registry of the images without registry, e.g. nginx:1.23
+const DefaultRegistry = "docker.io"
+
+var (
+	imageRepository = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	imageTag        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	imageDigest     = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// ImageRef is a parsed container image reference, <registry>/<repository>[:<tag>][@<digest>]
+type ImageRef struct {
+	// Registry host, with the port if any. DefaultRegistry if the image has no registry
+	Registry string
+	// Repository path, the official images of DefaultRegistry are prefixed with library/
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseImageRef parse an image reference, the registry and the repository are normalized,
+// e.g. nginx:1.23 is parsed as docker.io/library/nginx:1.23
+func ParseImageRef(image string) (ImageRef, error) {
+	ref := ImageRef{}
+	rest := image
+	if idx := strings.Index(rest, "@"); idx != -1 {
+		ref.Digest = rest[idx+1:]
+		rest = rest[:idx]
+		if !imageDigest.MatchString(ref.Digest) {
+			return ImageRef{}, fmt.Errorf("invalid digest in image %q", image)
+		}
+	}
+	if idx := strings.LastIndex(rest, ":"); idx != -1 && !strings.Contains(rest[idx:], "/") {
+		ref.Tag = rest[idx+1:]
+		rest = rest[:idx]
+		if !imageTag.MatchString(ref.Tag) {
+			return ImageRef{}, fmt.Errorf("invalid tag in image %q", image)
+		}
+	}
+	// The first component is a registry if it looks like a host
+	if first, path, found := strings.Cut(rest, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry = first
+		ref.Repository = path
+	} else {
+		ref.Registry = DefaultRegistry
+		ref.Repository = rest
+	}
+	if ref.Registry == DefaultRegistry && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if !imageRepository.MatchString(ref.Repository) {
+		return ImageRef{}, fmt.Errorf("invalid repository in image %q", image)
+	}
+	return ref, nil
+}
+
+// Name returns <registry>/<repository>
+func (r ImageRef) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// String returns the full reference, the digest is kept with the tag
+func (r ImageRef) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// NormalizeImagePrefix returns the registry or registry/repository prefix in the form of ImageRef.Name,
+// e.g. nginx is docker.io/library/nginx. A host alone, e.g. quay.io or localhost:5000, is kept as a registry prefix
+func NormalizeImagePrefix(prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.Contains(prefix, "/") && (strings.ContainsAny(prefix, ".:") || prefix == "localhost") {
+		return prefix
+	}
+	ref, err := ParseImageRef(prefix)
+	if err != nil || ref.Tag != "" || ref.Digest != "" {
+		return prefix
+	}
+	return ref.Name()
+}
+
+// HasPrefix returns true if the image is in the registry or the registry/repository prefix, e.g. quay.io/org
+func (r ImageRef) HasPrefix(prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	name := r.Name()
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// WithPrefix returns the reference with the prefix (a registry or a registry/repository prefix) replaced
+func (r ImageRef) WithPrefix(prefix, replacement string) ImageRef {
+	prefix = strings.TrimSuffix(prefix, "/")
+	replacement = strings.TrimSuffix(replacement, "/")
+	if !r.HasPrefix(prefix) {
+		return r
+	}
+	name := replacement + strings.TrimPrefix(r.Name(), prefix)
+	registry, repository, _ := strings.Cut(name, "/")
+	r.Registry = registry
+	r.Repository = repository
+	return r
+}
@@ -0,0 +1,68 @@
+package utils
+
+import "testing"
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		image   string
+		want    ImageRef
+		wantErr bool
+	}{
+		{image: "nginx", want: ImageRef{Registry: "docker.io", Repository: "library/nginx"}},
+		{image: "nginx:1.23", want: ImageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "1.23"}},
+		{image: "bitnami/redis:7.0", want: ImageRef{Registry: "docker.io", Repository: "bitnami/redis", Tag: "7.0"}},
+		{image: "docker.io/nginx", want: ImageRef{Registry: "docker.io", Repository: "library/nginx"}},
+		{image: "quay.io/org/app:v1", want: ImageRef{Registry: "quay.io", Repository: "org/app", Tag: "v1"}},
+		{image: "localhost/app", want: ImageRef{Registry: "localhost", Repository: "app"}},
+		{image: "localhost:5000/app:dev", want: ImageRef{Registry: "localhost:5000", Repository: "app", Tag: "dev"}},
+		{image: "registry.example.com:443/team/sub/app", want: ImageRef{Registry: "registry.example.com:443", Repository: "team/sub/app"}},
+		{
+			image: "nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			want:  ImageRef{Registry: "docker.io", Repository: "library/nginx", Digest: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		},
+		{
+			image: "quay.io/org/app:v1@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			want:  ImageRef{Registry: "quay.io", Repository: "org/app", Tag: "v1", Digest: "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		},
+		{image: "", wantErr: true},
+		{image: "Nginx", wantErr: true},
+		{image: "nginx:", wantErr: true},
+		{image: "nginx:-1", wantErr: true},
+		{image: "nginx@sha256:short", wantErr: true},
+		{image: "quay.io/", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := ParseImageRef(tt.image)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImageRef(%q) error = %v, wantErr %v", tt.image, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseImageRef(%q) = %+v, want %+v", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageRefString(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: "docker.io/library/nginx"},
+		{image: "nginx:1.23", want: "docker.io/library/nginx:1.23"},
+		{image: "quay.io/org/app:v1", want: "quay.io/org/app:v1"},
+		{image: "localhost:5000/app", want: "localhost:5000/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := ParseImageRef(tt.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ref.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
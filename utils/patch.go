@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/davidboxer/formation/types"
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PatchTargetMatch returns true if the target <type>/<name> of a patch match the resource, the type is case-insensitive
+func PatchTargetMatch(target, typeName, name string) (bool, error) {
+	targetType, targetName, found := strings.Cut(target, "/")
+	if !found {
+		return false, fmt.Errorf("invalid patch target %q, the format is <type>/<name>", target)
+	}
+	typeMatch, err := path.Match(strings.ToLower(targetType), strings.ToLower(typeName))
+	if err != nil {
+		return false, fmt.Errorf("invalid patch target %q: %w", target, err)
+	}
+	nameMatch, err := path.Match(targetName, name)
+	if err != nil {
+		return false, fmt.Errorf("invalid patch target %q: %w", target, err)
+	}
+	return typeMatch && nameMatch, nil
+}
+
+// ApplyPatch apply the patch to the object in place
+func ApplyPatch(obj client.Object, patch types.Patch) error {
+	patchJSON, err := yaml.ToJSON([]byte(patch.Patch))
+	if err != nil {
+		return fmt.Errorf("invalid patch for %s: %w", patch.Target, err)
+	}
+	patchType := patch.Type
+	if patchType == "" {
+		patchType = types.PatchTypeStrategicMerge
+		if bytes.HasPrefix(bytes.TrimSpace(patchJSON), []byte("[")) {
+			patchType = types.PatchTypeJSON6902
+		}
+	}
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	var patched []byte
+	_, isUnstructured := obj.(*unstructured.Unstructured)
+	switch {
+	case patchType == types.PatchTypeJSON6902:
+		operations, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return fmt.Errorf("invalid patch for %s: %w", patch.Target, err)
+		}
+		patched, err = operations.Apply(original)
+		if err != nil {
+			return fmt.Errorf("unable to patch %s: %w", patch.Target, err)
+		}
+	case patchType == types.PatchTypeMerge || (patchType == types.PatchTypeStrategicMerge && isUnstructured):
+		patched, err = jsonpatch.MergePatch(original, patchJSON)
+		if err != nil {
+			return fmt.Errorf("unable to patch %s: %w", patch.Target, err)
+		}
+	case patchType == types.PatchTypeStrategicMerge:
+		patched, err = strategicpatch.StrategicMergePatch(original, patchJSON, obj)
+		if err != nil {
+			return fmt.Errorf("unable to patch %s: %w", patch.Target, err)
+		}
+	default:
+		return fmt.Errorf("unknown patch type %s for %s", patchType, patch.Target)
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = nil
+		return u.UnmarshalJSON(patched)
+	}
+	// Decode in a new object so the fields removed by the patch are removed
+	value := reflect.New(reflect.TypeOf(obj).Elem())
+	if err := json.Unmarshal(patched, value.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(obj).Elem().Set(value.Elem())
+	return nil
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/davidboxer/formation/types"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func testDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Labels: map[string]string{"app": "api", "tier": "web"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ToPointer(int32(1)),
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{Name: "api", Image: "api:1"},
+						{Name: "proxy", Image: "proxy:1"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     client.Object
+		patch   types.Patch
+		wantErr bool
+		check   func(t *testing.T, obj client.Object)
+	}{
+		{
+			name:  "strategic merge the containers by name",
+			obj:   testDeployment(),
+			patch: types.Patch{Target: "deployment/api", Patch: "spec:\n  template:\n    spec:\n      containers:\n      - name: proxy\n        image: proxy:2\n"},
+			check: func(t *testing.T, obj client.Object) {
+				containers := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers
+				if len(containers) != 2 || containers[0].Image != "api:1" || containers[1].Image != "proxy:2" {
+					t.Errorf("containers = %+v", containers)
+				}
+			},
+		},
+		{
+			name:  "strategic merge delete a container",
+			obj:   testDeployment(),
+			patch: types.Patch{Target: "deployment/api", Type: types.PatchTypeStrategicMerge, Patch: `{"spec":{"template":{"spec":{"containers":[{"name":"proxy","$patch":"delete"}]}}}}`},
+			check: func(t *testing.T, obj client.Object) {
+				containers := obj.(*appsv1.Deployment).Spec.Template.Spec.Containers
+				if len(containers) != 1 || containers[0].Name != "api" {
+					t.Errorf("containers = %+v", containers)
+				}
+			},
+		},
+		{
+			name:  "merge patch replace the lists and remove the null fields",
+			obj:   testDeployment(),
+			patch: types.Patch{Target: "deployment/api", Type: types.PatchTypeMerge, Patch: "metadata:\n  labels:\n    tier: null\nspec:\n  template:\n    spec:\n      containers:\n      - name: proxy\n        image: proxy:2\n"},
+			check: func(t *testing.T, obj client.Object) {
+				deployment := obj.(*appsv1.Deployment)
+				if _, ok := deployment.Labels["tier"]; ok || deployment.Labels["app"] != "api" {
+					t.Errorf("labels = %v", deployment.Labels)
+				}
+				if containers := deployment.Spec.Template.Spec.Containers; len(containers) != 1 || containers[0].Name != "proxy" {
+					t.Errorf("containers = %+v", containers)
+				}
+			},
+		},
+		{
+			name:  "json6902 detected from the list",
+			obj:   testDeployment(),
+			patch: types.Patch{Target: "deployment/api", Patch: "- op: replace\n  path: /spec/replicas\n  value: 3\n- op: remove\n  path: /spec/template/spec/containers/1\n"},
+			check: func(t *testing.T, obj client.Object) {
+				deployment := obj.(*appsv1.Deployment)
+				if *deployment.Spec.Replicas != 3 || len(deployment.Spec.Template.Spec.Containers) != 1 {
+					t.Errorf("replicas = %d, containers = %+v", *deployment.Spec.Replicas, deployment.Spec.Template.Spec.Containers)
+				}
+			},
+		},
+		{
+			name:    "json6902 failing test operation",
+			obj:     testDeployment(),
+			patch:   types.Patch{Target: "deployment/api", Type: types.PatchTypeJSON6902, Patch: `[{"op":"test","path":"/spec/replicas","value":2}]`},
+			wantErr: true,
+		},
+		{
+			name: "unstructured strategic merge fallback to a merge patch",
+			obj: &unstructured.Unstructured{Object: map[string]any{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]any{"name": "widget"},
+				"spec":       map[string]any{"size": int64(1), "items": []any{"a", "b"}},
+			}},
+			patch: types.Patch{Target: "widget/widget", Patch: "spec:\n  items: [c]\n"},
+			check: func(t *testing.T, obj client.Object) {
+				u := obj.(*unstructured.Unstructured)
+				items, _, _ := unstructured.NestedStringSlice(u.Object, "spec", "items")
+				size, _, _ := unstructured.NestedInt64(u.Object, "spec", "size")
+				if len(items) != 1 || items[0] != "c" || size != 1 {
+					t.Errorf("spec = %v", u.Object["spec"])
+				}
+			},
+		},
+		{
+			name:    "unknown patch type",
+			obj:     testDeployment(),
+			patch:   types.Patch{Target: "deployment/api", Type: "apply", Patch: "spec: {}"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid yaml",
+			obj:     testDeployment(),
+			patch:   types.Patch{Target: "deployment/api", Patch: "spec: [\n"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyPatch(tt.obj, tt.patch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, tt.obj)
+			}
+		})
+	}
+}
+
+func TestPatchTargetMatch(t *testing.T) {
+	tests := []struct {
+		target   string
+		typeName string
+		name     string
+		want     bool
+		wantErr  bool
+	}{
+		{target: "deployment/api", typeName: "deployment", name: "api", want: true},
+		{target: "Deployment/api", typeName: "deployment", name: "api", want: true},
+		{target: "deployment/api-*", typeName: "deployment", name: "api-worker", want: true},
+		{target: "*/api", typeName: "service", name: "api", want: true},
+		{target: "deployment/api", typeName: "statefulset", name: "api"},
+		{target: "deployment/api", typeName: "deployment", name: "API"},
+		{target: "deployment", typeName: "deployment", name: "api", wantErr: true},
+		{target: "deployment/[", typeName: "deployment", name: "api", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			got, err := PatchTargetMatch(tt.target, tt.typeName, tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PatchTargetMatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PatchTargetMatch(%q, %q, %q) = %v, want %v", tt.target, tt.typeName, tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodTemplate returns the pod template of the workload, nil if the object does not have one
+func PodTemplate(obj client.Object) *v1.PodTemplateSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template
+	case *appsv1.ReplicaSet:
+		return &o.Spec.Template
+	case *batchv1.Job:
+		return &o.Spec.Template
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template
+	}
+	return nil
+}
+
+// PodSpec returns the pod spec of a Pod or of the pod template of a workload, nil if the object does not have one
+func PodSpec(obj client.Object) *v1.PodSpec {
+	if pod, ok := obj.(*v1.Pod); ok {
+		return &pod.Spec
+	}
+	if template := PodTemplate(obj); template != nil {
+		return &template.Spec
+	}
+	return nil
+}
+
+// RenameReferences rename the objects referenced by name by the object: the references of the pod spec, its
+// serviceAccountName, imagePullSecrets and subdomain, the service of a StatefulSet, the role and the service account
+// subjects of the bindings, the target of a HorizontalPodAutoscaler and the backends of an Ingress.
+// rename is called with the type (e.g. "configmap", "serviceaccount", "service") and the current name, it returns the new name
+func RenameReferences(obj client.Object, rename func(kind, name string) string) {
+	if spec := PodSpec(obj); spec != nil {
+		RenamePodSpecReferences(spec, rename)
+		if spec.ServiceAccountName != "" {
+			spec.ServiceAccountName = rename("serviceaccount", spec.ServiceAccountName)
+		}
+		for idx := range spec.ImagePullSecrets {
+			spec.ImagePullSecrets[idx].Name = rename("secret", spec.ImagePullSecrets[idx].Name)
+		}
+		// The subdomain is the name of the headless service of the pods
+		if spec.Subdomain != "" {
+			spec.Subdomain = rename("service", spec.Subdomain)
+		}
+	}
+	renameSubjects := func(namespace string, subjects []rbacv1.Subject) {
+		for idx := range subjects {
+			// Only the service accounts of the formation namespace are managed by the formation
+			if subjects[idx].Kind == rbacv1.ServiceAccountKind && (subjects[idx].Namespace == "" || subjects[idx].Namespace == namespace) {
+				subjects[idx].Name = rename("serviceaccount", subjects[idx].Name)
+			}
+		}
+	}
+	switch o := obj.(type) {
+	case *appsv1.StatefulSet:
+		if o.Spec.ServiceName != "" {
+			o.Spec.ServiceName = rename("service", o.Spec.ServiceName)
+		}
+	case *rbacv1.RoleBinding:
+		if o.RoleRef.Kind == "Role" {
+			o.RoleRef.Name = rename("role", o.RoleRef.Name)
+		} else if o.RoleRef.Kind == "ClusterRole" {
+			o.RoleRef.Name = rename("clusterrole", o.RoleRef.Name)
+		}
+		renameSubjects(o.Namespace, o.Subjects)
+	case *rbacv1.ClusterRoleBinding:
+		if o.RoleRef.Kind == "ClusterRole" {
+			o.RoleRef.Name = rename("clusterrole", o.RoleRef.Name)
+		}
+		renameSubjects("", o.Subjects)
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		o.Spec.ScaleTargetRef.Name = rename(strings.ToLower(o.Spec.ScaleTargetRef.Kind), o.Spec.ScaleTargetRef.Name)
+	case *networkingv1.Ingress:
+		renameBackend := func(backend *networkingv1.IngressBackend) {
+			if backend != nil && backend.Service != nil {
+				backend.Service.Name = rename("service", backend.Service.Name)
+			}
+		}
+		renameBackend(o.Spec.DefaultBackend)
+		for idx := range o.Spec.Rules {
+			if o.Spec.Rules[idx].HTTP == nil {
+				continue
+			}
+			for i := range o.Spec.Rules[idx].HTTP.Paths {
+				renameBackend(&o.Spec.Rules[idx].HTTP.Paths[i].Backend)
+			}
+		}
+		for idx := range o.Spec.TLS {
+			if o.Spec.TLS[idx].SecretName != "" {
+				o.Spec.TLS[idx].SecretName = rename("secret", o.Spec.TLS[idx].SecretName)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/davidboxer/formation/types"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ContainerSecurityContext returns the security context of a container for the profile.
+// The privileged profile does not restrict the container, nil is returned
+func ContainerSecurityContext(profile types.SecurityProfile) *v1.SecurityContext {
+	switch profile {
+	case types.SecurityProfileBaseline:
+		return &v1.SecurityContext{
+			Privileged:     ToPointer(false),
+			SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+		}
+	case types.SecurityProfileRestricted:
+		return &v1.SecurityContext{
+			AllowPrivilegeEscalation: ToPointer(false),
+			Capabilities: &v1.Capabilities{
+				Drop: []v1.Capability{"ALL"},
+			},
+			SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+			RunAsNonRoot:   ToPointer(true),
+		}
+	}
+	return nil
+}
+
+// PodSecurityContext returns the security context of a pod for the profile.
+// The ids left unset in ids are not set, so the platform can assign them
+func PodSecurityContext(profile types.SecurityProfile, ids types.SecurityIDs) *v1.PodSecurityContext {
+	ctx := &v1.PodSecurityContext{}
+	switch profile {
+	case types.SecurityProfileBaseline:
+		ctx.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault}
+	case types.SecurityProfileRestricted:
+		ctx.SeccompProfile = &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault}
+		ctx.RunAsNonRoot = ToPointer(true)
+	}
+	copied := ids.DeepCopy()
+	ctx.RunAsUser = copied.RunAsUser
+	ctx.RunAsGroup = copied.RunAsGroup
+	ctx.FSGroup = copied.FSGroup
+	return ctx
+}
+
+// PodSecurityReport is the result of CheckPodSecurity
+type PodSecurityReport struct {
+	// Level is the most restrictive profile satisfied by the pod
+	Level types.SecurityProfile
+	// Baseline and Restricted are the reasons the pod does not satisfy the profile
+	Baseline   []string
+	Restricted []string
+}
+
+// Satisfies returns true if the pod satisfies the profile
+func (r PodSecurityReport) Satisfies(profile types.SecurityProfile) bool {
+	switch profile {
+	case types.SecurityProfileBaseline:
+		return len(r.Baseline) == 0
+	case types.SecurityProfileRestricted:
+		return len(r.Baseline) == 0 && len(r.Restricted) == 0
+	}
+	return true
+}
+
+var (
+	baselineCapabilities = map[v1.Capability]bool{
+		"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+		"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+	}
+	baselineSysctls = map[string]bool{
+		"kernel.shm_rmid_forced": true, "net.ipv4.ip_local_port_range": true, "net.ipv4.ip_unprivileged_port_start": true,
+		"net.ipv4.tcp_syncookies": true, "net.ipv4.ping_group_range": true,
+	}
+	baselineSELinuxTypes = map[string]bool{"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true}
+)
+
+// CheckPodSecurity reports which Pod Security Standards profile the pod spec satisfies.
+// The AppArmor annotations are not checked, they are not part of the pod spec
+func CheckPodSecurity(spec *v1.PodSpec) PodSecurityReport {
+	report := PodSecurityReport{}
+	baseline := func(format string, args ...any) {
+		report.Baseline = append(report.Baseline, fmt.Sprintf(format, args...))
+	}
+	restricted := func(format string, args ...any) {
+		report.Restricted = append(report.Restricted, fmt.Sprintf(format, args...))
+	}
+
+	podCtx := spec.SecurityContext
+	if podCtx == nil {
+		podCtx = &v1.PodSecurityContext{}
+	}
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		baseline("host namespaces are not allowed")
+	}
+	if podCtx.WindowsOptions != nil && podCtx.WindowsOptions.HostProcess != nil && *podCtx.WindowsOptions.HostProcess {
+		baseline("host process is not allowed")
+	}
+	checkSELinux(podCtx.SELinuxOptions, "pod", baseline)
+	if podCtx.SeccompProfile != nil && podCtx.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+		baseline("pod seccomp profile can not be Unconfined")
+	}
+	for _, sysctl := range podCtx.Sysctls {
+		if !baselineSysctls[sysctl.Name] {
+			baseline("sysctl %s is not allowed", sysctl.Name)
+		}
+	}
+	if podCtx.RunAsUser != nil && *podCtx.RunAsUser == 0 {
+		restricted("pod can not run as root user")
+	}
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.HostPath != nil:
+			baseline("hostPath volume %s is not allowed", volume.Name)
+		case volume.ConfigMap != nil, volume.CSI != nil, volume.DownwardAPI != nil, volume.EmptyDir != nil, volume.Ephemeral != nil,
+			volume.PersistentVolumeClaim != nil, volume.Projected != nil, volume.Secret != nil:
+		default:
+			restricted("volume %s type is not allowed", volume.Name)
+		}
+	}
+
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		ctx := container.SecurityContext
+		if ctx == nil {
+			ctx = &v1.SecurityContext{}
+		}
+		name := "container " + container.Name
+		if ctx.Privileged != nil && *ctx.Privileged {
+			baseline("%s can not be privileged", name)
+		}
+		if ctx.WindowsOptions != nil && ctx.WindowsOptions.HostProcess != nil && *ctx.WindowsOptions.HostProcess {
+			baseline("%s can not be a host process", name)
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				baseline("%s can not use the host port %d", name, port.HostPort)
+			}
+		}
+		checkSELinux(ctx.SELinuxOptions, name, baseline)
+		if ctx.ProcMount != nil && *ctx.ProcMount != v1.DefaultProcMount {
+			baseline("%s proc mount must be Default", name)
+		}
+
+		// Seccomp, the container inherit the pod profile
+		seccomp := podCtx.SeccompProfile
+		if ctx.SeccompProfile != nil {
+			seccomp = ctx.SeccompProfile
+		}
+		if ctx.SeccompProfile != nil && ctx.SeccompProfile.Type == v1.SeccompProfileTypeUnconfined {
+			baseline("%s seccomp profile can not be Unconfined", name)
+		}
+		if seccomp == nil || (seccomp.Type != v1.SeccompProfileTypeRuntimeDefault && seccomp.Type != v1.SeccompProfileTypeLocalhost) {
+			restricted("%s seccomp profile must be RuntimeDefault or Localhost", name)
+		}
+
+		// Capabilities
+		dropAll := false
+		if ctx.Capabilities != nil {
+			for _, capability := range ctx.Capabilities.Add {
+				if !baselineCapabilities[capability] {
+					baseline("%s can not add the capability %s", name, capability)
+				} else if capability != "NET_BIND_SERVICE" {
+					restricted("%s can only add the capability NET_BIND_SERVICE", name)
+				}
+			}
+			for _, capability := range ctx.Capabilities.Drop {
+				if capability == "ALL" {
+					dropAll = true
+				}
+			}
+		}
+		if !dropAll {
+			restricted("%s must drop ALL capabilities", name)
+		}
+
+		if ctx.AllowPrivilegeEscalation == nil || *ctx.AllowPrivilegeEscalation {
+			restricted("%s must set allowPrivilegeEscalation to false", name)
+		}
+		runAsNonRoot := podCtx.RunAsNonRoot
+		if ctx.RunAsNonRoot != nil {
+			runAsNonRoot = ctx.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			restricted("%s must set runAsNonRoot to true", name)
+		}
+		if ctx.RunAsUser != nil && *ctx.RunAsUser == 0 {
+			restricted("%s can not run as root user", name)
+		}
+	}
+
+	switch {
+	case len(report.Baseline) > 0:
+		report.Level = types.SecurityProfilePrivileged
+	case len(report.Restricted) > 0:
+		report.Level = types.SecurityProfileBaseline
+	default:
+		report.Level = types.SecurityProfileRestricted
+	}
+	return report
+}
+
+func checkSELinux(options *v1.SELinuxOptions, name string, report func(format string, args ...any)) {
+	if options == nil {
+		return
+	}
+	if !baselineSELinuxTypes[options.Type] {
+		report("%s SELinux type %s is not allowed", name, options.Type)
+	}
+	if options.User != "" || options.Role != "" {
+		report("%s can not set the SELinux user or role", name)
+	}
+}
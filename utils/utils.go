@@ -1,6 +1,12 @@
 package utils
 
-import v1 "k8s.io/api/core/v1"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+)
 
 // MergeResourceRequirements Merge src onto dest, overwriting any existing values in dest
 func MergeResourceRequirements(dest, src v1.ResourceRequirements) *v1.ResourceRequirements {
@@ -146,6 +152,140 @@ func MergeLocalObjectReference(dest, src []v1.LocalObjectReference) []v1.LocalOb
 	return rst
 }
 
+// PodSpecReferences returns the sorted names of the ConfigMaps and Secrets referenced by the pod spec.
+// Volumes, projected volumes, envFrom and env valueFrom of all the containers are scanned
+func PodSpecReferences(spec *v1.PodSpec) (configMaps []string, secrets []string) {
+	configMapSet := map[string]struct{}{}
+	secretSet := map[string]struct{}{}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			configMapSet[volume.ConfigMap.Name] = struct{}{}
+		}
+		if volume.Secret != nil {
+			secretSet[volume.Secret.SecretName] = struct{}{}
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMapSet[source.ConfigMap.Name] = struct{}{}
+				}
+				if source.Secret != nil {
+					secretSet[source.Secret.Name] = struct{}{}
+				}
+			}
+		}
+	}
+	containers := append(append([]v1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMapSet[envFrom.ConfigMapRef.Name] = struct{}{}
+			}
+			if envFrom.SecretRef != nil {
+				secretSet[envFrom.SecretRef.Name] = struct{}{}
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMapSet[env.ValueFrom.ConfigMapKeyRef.Name] = struct{}{}
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secretSet[env.ValueFrom.SecretKeyRef.Name] = struct{}{}
+			}
+		}
+	}
+	return SortedKeys(configMapSet), SortedKeys(secretSet)
+}
+
+// RenamePodSpecReferences rename the ConfigMaps, Secrets and PersistentVolumeClaims referenced by the pod spec.
+// rename is called with the type ("configmap", "secret" or "persistentvolumeclaim") and the current name, it returns the new name
+func RenamePodSpecReferences(spec *v1.PodSpec, rename func(kind, name string) string) {
+	for idx := range spec.Volumes {
+		volume := &spec.Volumes[idx]
+		if volume.ConfigMap != nil {
+			volume.ConfigMap.Name = rename("configmap", volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			volume.Secret.SecretName = rename("secret", volume.Secret.SecretName)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			volume.PersistentVolumeClaim.ClaimName = rename("persistentvolumeclaim", volume.PersistentVolumeClaim.ClaimName)
+		}
+		if volume.Projected != nil {
+			for i := range volume.Projected.Sources {
+				source := &volume.Projected.Sources[i]
+				if source.ConfigMap != nil {
+					source.ConfigMap.Name = rename("configmap", source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					source.Secret.Name = rename("secret", source.Secret.Name)
+				}
+			}
+		}
+	}
+	renameContainers := func(containers []v1.Container) {
+		for idx := range containers {
+			container := &containers[idx]
+			for i := range container.EnvFrom {
+				if container.EnvFrom[i].ConfigMapRef != nil {
+					container.EnvFrom[i].ConfigMapRef.Name = rename("configmap", container.EnvFrom[i].ConfigMapRef.Name)
+				}
+				if container.EnvFrom[i].SecretRef != nil {
+					container.EnvFrom[i].SecretRef.Name = rename("secret", container.EnvFrom[i].SecretRef.Name)
+				}
+			}
+			for i := range container.Env {
+				valueFrom := container.Env[i].ValueFrom
+				if valueFrom == nil {
+					continue
+				}
+				if valueFrom.ConfigMapKeyRef != nil {
+					valueFrom.ConfigMapKeyRef.Name = rename("configmap", valueFrom.ConfigMapKeyRef.Name)
+				}
+				if valueFrom.SecretKeyRef != nil {
+					valueFrom.SecretKeyRef.Name = rename("secret", valueFrom.SecretKeyRef.Name)
+				}
+			}
+		}
+	}
+	renameContainers(spec.InitContainers)
+	renameContainers(spec.Containers)
+}
+
+// MergeMap copy the entries of src into dest and returns dest, dest is created if it is nil and src is not empty.
+// MergeMap(nil, m) returns a copy of m
+func MergeMap(dest, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dest
+	}
+	if dest == nil {
+		dest = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dest[k] = v
+	}
+	return dest
+}
+
+// SortedKeys returns the keys of the map in order
+func SortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func ToPointer[T any](v T) *T {
 	return &v
 }
+
+// HashLabelValue returns the hex sha224 of the value, it fits the 63 characters limit of the label values
+func HashLabelValue(value string) string {
+	sum := sha256.Sum224([]byte(value))
+	return hex.EncodeToString(sum[:])
+}